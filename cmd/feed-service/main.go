@@ -1,53 +1,185 @@
 package main
 
 import (
+	"os"
+	"log/slog"
+	"context"
+	"sync/atomic"
+	"strconv"
+	"net/http"
+	"database/sql"
+
 	"github.com/gin-gonic/gin"
 
 	"feed-service/internal/models"
 	"feed-service/internal/middleware"
+	"feed-service/pkg/db/memory"
 	"feed-service/pkg/db/postgres"
 )
 
 func main() {
 	var cfg models.Config
 
-	cfg.PostgresUser.GetEnv("POSTGRES_USER")
-	cfg.PostgresPassword.GetEnv("POSTGRES_PASSWORD")
-	cfg.PostgresDBName.GetEnv("POSTGRES_DBNAME")
-	cfg.PostgresHost.GetEnv("POSTGRES_HOST")
-	cfg.PostgresPort.GetEnv("POSTGRES_PORT")
-	cfg.RouterHost.GetEnv("ROUTER_HOST")
-	cfg.RouterPort.GetEnv("ROUTER_PORT")
-	cfg.ServiceVersion.GetEnv("SERVICE_VERSION")
+	// JSON logs, `log` package output included. Level is known once config is loaded
+	var logLevel slog.LevelVar
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{ Level: &logLevel }))
+	slog.SetDefault(logger)
 
-	postgreSQLConfig := postgres.PostgreSQLConfig{
-		User	: cfg.PostgresUser.String(),
-		Password: cfg.PostgresPassword.String(),
-		DBName	: cfg.PostgresDBName.String(),
-		Host	: cfg.PostgresHost.String(),
-		Port	: cfg.PostgresPort.String(),
+	// optional, env variables override values from it
+	configFile := os.Getenv("CONFIG_FILE")
+
+	// replaced by secret rotation, see below
+	var dbPassword atomic.Value
+
+	// `feed-service migrate ...` only manages schema, service is not started.
+	// It needs the database only, the rest of config is not loaded
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := models.Load(&cfg.PostgresConfig, configFile); err != nil {
+			panic(err)
+		}
+
+		conn, err := connect(&cfg.PostgresConfig, &dbPassword)
+		if err != nil {
+			panic(err)
+		}
+		defer conn.Close()
+
+		migrator, err := postgres.NewMigrator(conn)
+		if err != nil {
+			panic(err)
+		}
+		if err := migrate(migrator, os.Args[2:]); err != nil {
+			panic(err)
+		}
+		return
 	}
 
-	conn, err := postgres.NewPostgresDB(&postgreSQLConfig);
-	if err != nil {
+	if err := models.Load(&cfg, configFile); err != nil {
 		panic(err)
 	}
+	logLevel.Set(cfg.LogLevel)
+
+	var conn *sql.DB
+	var store models.PostStore
+	switch cfg.Storage {
+	case models.StorageMemory:
+		slog.Warn("posts are kept in memory and lost on restart, STORAGE=memory is not meant for production")
+		store = memory.NewPostStore()
+	default:
+		var err error
+		conn, err = connect(&cfg.PostgresConfig, &dbPassword)
+		if err != nil {
+			panic(err)
+		}
+		defer conn.Close()
+
+		if cfg.MigrateOnStart {
+			migrator, err := postgres.NewMigrator(conn)
+			if err != nil {
+				panic(err)
+			}
+			if _, err := migrator.Up(context.Background()); err != nil {
+				panic(err)
+			}
+		}
+		store = postgres.NewPostStore(conn)
+	}
+
+	// nil conn skips pool stats and the postgres readiness probe
+	metrics := middleware.NewMetrics(conn)
 
 	ctrl := middleware.Controller {
 		Cfg: &cfg,
+		Store: store,
+		QueryTimeout: cfg.QueryTimeout,
 		DB: conn,
+		ReadinessTimeout: cfg.ReadinessTimeout,
+		Metrics: metrics,
 	}
 
 	gin.EnableJsonDecoderDisallowUnknownFields()
-	router := gin.Default()
-	router.POST("/like", ctrl.PostLike)
-	router.POST("/dislike", ctrl.PostDislike)
-	router.POST("/new-post", ctrl.PostNewPost)
+	router := gin.New()
+	// Logger and metrics have to see the response written by Recovery
+	router.Use(middleware.RequestID(), middleware.Logger(logger), metrics.Middleware(), middleware.Recovery())
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(middleware.NoRoute)
+	router.NoMethod(middleware.NoMethod)
 	router.GET("/posts", ctrl.GetPosts)
+	router.GET("/posts/search", ctrl.SearchPosts)
+	router.GET("/posts/:uuid", ctrl.GetPost)
+	router.GET("/users/:id/posts", ctrl.GetUserPosts)
 	router.GET("/healthz", ctrl.GetHealthz)
+	router.GET("/livez", ctrl.GetLivez)
+	router.GET("/readyz", ctrl.GetReadyz)
+	router.GET("/metrics", metrics.Handler())
+
+	// everything that changes data requires authenticated user
+	authenticator := middleware.NewJWTAuthenticator([]byte(cfg.JWTSecret.String()))
+	authorized := router.Group("/", middleware.Auth(authenticator))
+	// per replica, see middleware.RateLimiter
+	limiter := middleware.NewMemoryRateLimiter()
+	reactionsLimit := middleware.RateLimit(limiter, "reactions", cfg.RateLimitReactions)
+	authorized.POST("/like", reactionsLimit, ctrl.PostLike)
+	authorized.POST("/dislike", reactionsLimit, ctrl.PostDislike)
+	authorized.POST("/clear-reaction", reactionsLimit, ctrl.PostClearReaction)
+	authorized.POST("/new-post", middleware.RateLimit(limiter, "new-post", cfg.RateLimitNewPost), ctrl.PostNewPost)
+	authorized.PATCH("/posts/:uuid", ctrl.PatchPost)
+	authorized.DELETE("/posts/:uuid", ctrl.DeletePost)
+
+	// secrets mounted as files are rotated without restart: new DB connections
+	// use the new password, tokens are checked with the new key
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	if conn != nil {
+		go models.WatchSecretFile(watchCtx, "POSTGRES_PASSWORD", cfg.PostgresPassword.String(), cfg.SecretReloadInterval, func(password string) {
+			dbPassword.Store(password)
+		})
+	}
+	go models.WatchSecretFile(watchCtx, "JWT_SECRET", cfg.JWTSecret.String(), cfg.SecretReloadInterval, func(secret string) {
+		authenticator.SetKey([]byte(secret))
+	})
+
+	srv := &http.Server{
+		Addr: cfg.RouterHost.String() + ":" + strconv.Itoa(cfg.RouterPort),
+		Handler: router,
+	}
 
-	addrStr := cfg.RouterHost.String() + ":" + cfg.RouterPort.String()
-	if err:= router.Run(addrStr); err != nil {
+	// DB pool, if any, is closed by defer once every request is done with it
+	if err := serve(srv, &ctrl, cfg.ShutdownDelay, cfg.ShutdownTimeout); err != nil {
 		panic(err)
 	}
+	slog.Info("shutdown complete")
+}
+
+// opens the pool, password is read from password on every new connection
+func connect(cfg *models.PostgresConfig, password *atomic.Value) (*sql.DB, error) {
+	password.Store(cfg.PostgresPassword.String())
+
+	postgreSQLConfig := postgres.PostgreSQLConfig{
+		User	: cfg.PostgresUser.String(),
+		Password: cfg.PostgresPassword.String(),
+		PasswordFunc: func() string { return password.Load().(string) },
+		DBName	: cfg.PostgresDBName.String(),
+		Host	: cfg.PostgresHost.String(),
+		Port	: strconv.Itoa(cfg.PostgresPort),
+
+		SSLMode		: cfg.PostgresSSLMode.String(),
+		SSLRootCert	: cfg.PostgresSSLRootCert.String(),
+		SSLCert		: cfg.PostgresSSLCert.String(),
+		SSLKey		: cfg.PostgresSSLKey.String(),
+		ConnectTimeout	: cfg.PostgresConnectTimeout,
+
+		MaxOpenConns	: cfg.PostgresMaxOpenConns,
+		MaxIdleConns	: cfg.PostgresMaxIdleConns,
+		ConnMaxLifetime	: cfg.PostgresConnMaxLifetime,
+		ConnMaxIdleTime	: cfg.PostgresConnMaxIdleTime,
+
+		Retry: postgres.RetryPolicy{
+			InitialInterval	: cfg.PostgresRetryInitialInterval,
+			MaxInterval		: cfg.PostgresRetryMaxInterval,
+			MaxWait			: cfg.PostgresRetryMaxWait,
+		},
+	}
+
+	return postgres.NewPostgresDB(&postgreSQLConfig)
 }
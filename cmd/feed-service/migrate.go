@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"feed-service/pkg/db/postgres"
+)
+
+const migrateUsage = "usage: feed-service migrate up | down [steps] | version"
+
+// handles `feed-service migrate ...` subcommand
+func migrate(m *postgres.Migrator, args []string) error {
+	ctx := context.Background()
+
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, v := range applied {
+			fmt.Println("applied", v)
+		}
+		return err
+
+	case "down":
+		// revert the latest migration only, unless told otherwise
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return errors.New(migrateUsage)
+			}
+			steps = n
+		}
+
+		reverted, err := m.Down(ctx, steps)
+		for _, v := range reverted {
+			fmt.Println("reverted", v)
+		}
+		return err
+
+	case "version":
+		version, err := m.Version(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println(version)
+		return nil
+	}
+
+	return errors.New(migrateUsage)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"feed-service/internal/middleware"
+)
+
+// serves until SIGINT/SIGTERM, then drains connections.
+// First healthz reports not-ready for `delay`, so traffic is routed away,
+// then requests in flight are given `timeout` to finish
+func serve(srv *http.Server, ctrl *middleware.Controller, delay time.Duration, timeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		// failed to start, nothing to drain
+		return err
+	case <-ctx.Done():
+	}
+	// second signal kills the process right away
+	stop()
+
+	slog.Info("shutting down, draining connections")
+	ctrl.SetShuttingDown()
+	time.Sleep(delay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"sync"
+	"errors"
+	"strings"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+
+	"feed-service/internal/models"
+)
+
+// gin.Context key holding models.Identity of authenticated request
+const identityKey = "identity"
+
+// Authenticator validates bearer token and tells who made the request
+type Authenticator interface {
+	Authenticate(token string) (models.Identity, error)
+}
+
+// JWTAuthenticator accepts JWTs signed with HMAC (HS256/384/512),
+// user id is taken from `sub` claim
+type JWTAuthenticator struct {
+	// use SetKey once requests are served
+	Key		[]byte
+	mu		sync.RWMutex
+}
+
+func NewJWTAuthenticator(key []byte) *JWTAuthenticator {
+	return &JWTAuthenticator{
+		Key: key,
+	}
+}
+
+// SetKey replaces the key for tokens validated from now on, e.g. after secret rotation
+func (a *JWTAuthenticator) SetKey(key []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Key = key
+}
+
+func (a *JWTAuthenticator) key() []byte {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.Key
+}
+
+func (a *JWTAuthenticator) Authenticate(token string) (models.Identity, error) {
+	var claims jwt.RegisteredClaims
+	key := a.key()
+
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		// never trust `alg` from the token itself
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method " + t.Method.Alg())
+		}
+		return key, nil
+	})
+	if err != nil {
+		return models.Identity{}, err
+	}
+
+	if claims.Subject == "" {
+		return models.Identity{}, errors.New("token has no subject")
+	}
+	return models.Identity{ UserID: claims.Subject }, nil
+}
+
+func unauthorized(c *gin.Context, msg string) {
+	c.Header("WWW-Authenticate", "Bearer")
+	abortWithError(c, http.StatusUnauthorized, codeUnauthorized, msg)
+}
+
+// Auth rejects requests without valid `Authorization: Bearer <token>` header,
+// identity of the rest is attached to gin.Context
+func Auth(a Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scheme, token, found := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			unauthorized(c, "Provide `Authorization: Bearer <token>` header")
+			return
+		}
+
+		id, err := a.Authenticate(token)
+		if err != nil {
+			unauthorized(c, "Invalid token")
+			return
+		}
+
+		c.Set(identityKey, id)
+		c.Next()
+	}
+}
+
+// identity attached by Auth, ok == false for anonymous requests
+func identity(c *gin.Context) (models.Identity, bool) {
+	v, ok := c.Get(identityKey)
+	if !ok {
+		return models.Identity{}, false
+	}
+
+	id, ok := v.(models.Identity)
+	return id, ok
+}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"time"
+	"testing"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+
+	"feed-service/internal/models"
+)
+
+var testKey = []byte("qwerty123")
+
+// pretends request was authenticated by Auth
+func asUser(user string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(identityKey, models.Identity{ UserID: user })
+	}
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.Claims) string {
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	assert.NoError(t, err)
+	return token
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	a := NewJWTAuthenticator(testKey)
+
+	t.Run("valid", func(t *testing.T) {
+		t.Parallel()
+
+		token := signToken(t, jwt.SigningMethodHS256, testKey, jwt.RegisteredClaims{
+			Subject: "penny",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		})
+
+		id, err := a.Authenticate(token)
+		assert.NoError(t, err)
+		assert.Equal(t, "penny", id.UserID)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		t.Parallel()
+
+		token := signToken(t, jwt.SigningMethodHS256, []byte("asd"), jwt.RegisteredClaims{
+			Subject: "penny",
+		})
+
+		_, err := a.Authenticate(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		t.Parallel()
+
+		token := signToken(t, jwt.SigningMethodHS256, testKey, jwt.RegisteredClaims{
+			Subject: "penny",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		})
+
+		_, err := a.Authenticate(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("no subject", func(t *testing.T) {
+		t.Parallel()
+
+		token := signToken(t, jwt.SigningMethodHS256, testKey, jwt.RegisteredClaims{})
+
+		_, err := a.Authenticate(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("alg none", func(t *testing.T) {
+		t.Parallel()
+
+		token := signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, jwt.RegisteredClaims{
+			Subject: "penny",
+		})
+
+		_, err := a.Authenticate(token)
+		assert.Error(t, err)
+	})
+}
+
+func TestJWTAuthenticatorSetKey(t *testing.T) {
+	a := NewJWTAuthenticator(testKey)
+	newKey := []byte("rotated")
+
+	oldToken := signToken(t, jwt.SigningMethodHS256, testKey, jwt.RegisteredClaims{ Subject: "penny" })
+	newToken := signToken(t, jwt.SigningMethodHS256, newKey, jwt.RegisteredClaims{ Subject: "penny" })
+
+	a.SetKey(newKey)
+
+	_, err := a.Authenticate(oldToken)
+	assert.Error(t, err)
+
+	id, err := a.Authenticate(newToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "penny", id.UserID)
+}
+
+func TestAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+	router.GET("/private", Auth(NewJWTAuthenticator(testKey)), func(c *gin.Context) {
+		id, _ := identity(c)
+		c.String(http.StatusOK, id.UserID)
+	})
+
+	valid := signToken(t, jwt.SigningMethodHS256, testKey, jwt.RegisteredClaims{
+		Subject: "penny",
+	})
+
+	cases := []struct {
+		name	string
+		header	string
+		code	int
+	}{
+		{ "valid", "Bearer " + valid, http.StatusOK },
+		{ "lowercase scheme", "bearer " + valid, http.StatusOK },
+		{ "no header", "", http.StatusUnauthorized },
+		{ "basic", "Basic cGVubnk6cXdlcnR5MTIz", http.StatusUnauthorized },
+		{ "garbage", "Bearer asd", http.StatusUnauthorized },
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// record request
+			rr := httptest.NewRecorder()
+
+			// mock request
+			request, err := http.NewRequest(http.MethodGet, "/private", nil)
+			assert.NoError(t, err)
+			if tc.header != "" {
+				request.Header.Set("Authorization", tc.header)
+			}
+
+			// make request
+			router.ServeHTTP(rr, request)
+
+			assert.Equal(t, tc.code, rr.Code)
+			if tc.code == http.StatusOK {
+				assert.Equal(t, "penny", rr.Body.String())
+			} else {
+				assert.Equal(t, "Bearer", rr.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
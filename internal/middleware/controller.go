@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"time"
 	"database/sql"
+	"sync/atomic"
 
 	"feed-service/internal/models"
 )
@@ -9,5 +11,25 @@ import (
 // Base class for any API
 type Controller struct {
 	Cfg		*models.Config
+	Store	models.PostStore
+	// deadline of every Store call, none if 0
+	QueryTimeout	time.Duration
+	// probed by /readyz
 	DB		*sql.DB
+	// how long /readyz waits for each dependency, defaultReadinessTimeout if 0
+	ReadinessTimeout	time.Duration
+	// nil disables business metrics
+	Metrics		*Metrics
+
+	// set once shutdown begins, accessed atomically
+	shuttingDown	int32
+}
+
+// reports the service as not ready, requests in flight are still served
+func (h *Controller) SetShuttingDown() {
+	atomic.StoreInt32(&h.shuttingDown, 1)
+}
+
+func (h *Controller) isShuttingDown() bool {
+	return atomic.LoadInt32(&h.shuttingDown) == 1
 }
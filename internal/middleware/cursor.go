@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"time"
+	"net/http"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+
+	"feed-service/internal/models"
+)
+
+// cursor holds the sort key of the last post on a page.
+// Clients receive it as an opaque token and should never parse it
+type cursor struct {
+	// what the cursor pages through: sort of the feed or cursorSearch,
+	// a cursor of one is meaningless for another
+	Kind		string		`json:"k"`
+	// search results only
+	Rank		float32		`json:"r,omitempty"`
+	// feed sorted by score only
+	Score		float64		`json:"s,omitempty"`
+	CreatedAt	time.Time	`json:"t"`
+	UUID		string		`json:"u"`
+}
+
+const cursorSearch = "search"
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+func cursorFromPost(p *models.Post, sort models.PostSort) cursor {
+	return cursor{
+		Kind: string(sort),
+		Score: p.Score,
+		CreatedAt: p.CreatedAt,
+		UUID: p.UUID,
+	}
+}
+
+func cursorFromResult(r *models.SearchResult) cursor {
+	cur := cursorFromPost(&r.Post, "")
+	cur.Kind = cursorSearch
+	cur.Rank = r.Rank
+	return cur
+}
+
+func encodeCursor(cur cursor) string {
+	// marshaling of a plain struct can not fail
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// cursor of the given kind, cursor of any other kind is invalid
+func decodeCursor(s string, kind string) (cursor, error) {
+	var cur cursor
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cur, errInvalidCursor
+	}
+
+	if err := json.Unmarshal(b, &cur); err != nil {
+		return cur, errInvalidCursor
+	}
+
+	if cur.Kind != kind || !isValidUUID(cur.UUID) {
+		return cur, errInvalidCursor
+	}
+	return cur, nil
+}
+
+// page requested with `limit`, `since`, `until` and `after`,
+// the feed and search are paginated the same way
+type page struct {
+	limit		uint64
+	hasLimit	bool
+	since		time.Time
+	until		time.Time
+	// nil for the first page
+	after		*cursor
+}
+
+// reads page parameters, `after` has to be a cursor of the given kind
+func parsePage(c *gin.Context, kind string) (page, error) {
+	var p page
+	var err error
+
+	if p.limit, p.hasLimit, err = parseLimit(c); err != nil {
+		return p, err
+	}
+	if p.since, _, err = parseTime(c, "since"); err != nil {
+		return p, err
+	}
+	if p.until, _, err = parseTime(c, "until"); err != nil {
+		return p, err
+	}
+
+	if after, ok := c.GetQuery("after"); ok {
+		cur, err := decodeCursor(after, kind)
+		if err != nil {
+			return p, errors.New("Parameter `after` is invalid.\n`after`=" + after)
+		}
+		p.after = &cur
+	}
+	return p, nil
+}
+
+// limit for the store, 0 for no limit
+func (p page) storeLimit() int {
+	if !p.hasLimit {
+		return 0
+	}
+	// one extra item tells whether there is a next page
+	return int(p.limit) + 1
+}
+
+// responds with items of the page, `next_cursor` points past the last one unless the page is the last
+func writePage[T any](c *gin.Context, p page, items []T, cursorOf func(*T) cursor) {
+	var nextCursor *string
+	if p.hasLimit && uint64(len(items)) > p.limit {
+		items = items[:p.limit]
+		if p.limit > 0 {
+			token := encodeCursor(cursorOf(&items[len(items) - 1]))
+			nextCursor = &token
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H {
+		"data": items,
+		"total": len(items),
+		"next_cursor": nextCursor,
+	})
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"time"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"feed-service/internal/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	post := models.Post {
+		UUID: "eaeaa9c9-85c0-4c53-9309-9d499c6c0026",
+		CreatedAt: time.Date(2022, 7, 1, 12, 0, 0, 123, time.UTC),
+	}
+
+	token := encodeCursor(cursorFromPost(&post, models.SortNew))
+
+	cur, err := decodeCursor(token, string(models.SortNew))
+	assert.NoError(t, err)
+	assert.Equal(t, post.UUID, cur.UUID)
+	assert.True(t, post.CreatedAt.Equal(cur.CreatedAt))
+}
+
+// rank has to come back exactly, Postgres compares it with `real` values
+func TestSearchCursorRoundTrip(t *testing.T) {
+	res := models.SearchResult {
+		Post: models.Post {
+			UUID: "eaeaa9c9-85c0-4c53-9309-9d499c6c0026",
+			CreatedAt: time.Date(2022, 7, 1, 12, 0, 0, 123, time.UTC),
+		},
+		Rank: 0.0607927,
+	}
+
+	cur, err := decodeCursor(encodeCursor(cursorFromResult(&res)), cursorSearch)
+	assert.NoError(t, err)
+	assert.Equal(t, res.Rank, cur.Rank)
+	assert.Equal(t, res.UUID, cur.UUID)
+	assert.True(t, res.CreatedAt.Equal(cur.CreatedAt))
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	t.Run("not base64", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := decodeCursor("%%%", cursorSearch)
+		assert.ErrorIs(t, err, errInvalidCursor)
+	})
+
+	t.Run("not json", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := decodeCursor("YXNk", cursorSearch)
+		assert.ErrorIs(t, err, errInvalidCursor)
+	})
+
+	t.Run("bad uuid", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := decodeCursor(encodeCursor(cursor{Kind: cursorSearch, UUID: "asd"}), cursorSearch)
+		assert.ErrorIs(t, err, errInvalidCursor)
+	})
+
+	// feed cursor passed to search and the other way round
+	t.Run("other kind", func(t *testing.T) {
+		t.Parallel()
+
+		post := models.Post{ UUID: "eaeaa9c9-85c0-4c53-9309-9d499c6c0026" }
+
+		_, err := decodeCursor(encodeCursor(cursorFromPost(&post, models.SortNew)), cursorSearch)
+		assert.ErrorIs(t, err, errInvalidCursor)
+
+		_, err = decodeCursor(encodeCursor(cursorFromResult(&models.SearchResult{ Post: post })), string(models.SortNew))
+		assert.ErrorIs(t, err, errInvalidCursor)
+	})
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// machine readable error codes, clients are expected to switch on them
+const (
+	codeInvalidParameter	= "invalid_parameter"
+	codeInvalidBody			= "invalid_body"
+	codeEmptyContent		= "empty_content"
+	codeUnauthorized		= "unauthorized"
+	codeForbidden			= "forbidden"
+	codePostNotFound		= "post_not_found"
+	codeRouteNotFound		= "route_not_found"
+	codeMethodNotAllowed	= "method_not_allowed"
+	codeRateLimited			= "rate_limited"
+	codeInternal			= "internal_error"
+	codeUnavailable			= "unavailable"
+	codeTimeout				= "timeout"
+)
+
+type errorBody struct {
+	Code		string	`json:"code"`
+	Message		string	`json:"message"`
+	RequestID	string	`json:"request_id"`
+}
+
+// every error response is `{"error": errorBody}`
+type errorResponse struct {
+	Error		errorBody	`json:"error"`
+}
+
+// id set by RequestID, otherwise the one client has sent, if any
+func requestID(c *gin.Context) string {
+	if id := c.GetString(requestIDKey); id != "" {
+		return id
+	}
+
+	// contexts created outside of router may have no request
+	if c.Request == nil {
+		return ""
+	}
+	return c.GetHeader(requestIDHeader)
+}
+
+// aborts the request with error envelope
+func abortWithError(c *gin.Context, status int, code string, message string) {
+	c.AbortWithStatusJSON(status, errorResponse{
+		Error: errorBody{
+			Code: code,
+			Message: message,
+			RequestID: requestID(c),
+		},
+	})
+}
+
+func invalidParameter(c *gin.Context, message string) {
+	abortWithError(c, http.StatusBadRequest, codeInvalidParameter, message)
+}
+
+func postNotFound(c *gin.Context, u string) {
+	abortWithError(c, http.StatusNotFound, codePostNotFound, "Post `" + u + "` not found")
+}
+
+// err is kept in c.Errors for logging, client gets generic message only
+func internalError(c *gin.Context, err error) {
+	// `_ =` to silence lint, returned value is the same error
+	_ = c.Error(err)
+	abortWithError(c, http.StatusInternalServerError, codeInternal, "Internal server error")
+}
+
+// handler for router.NoRoute
+func NoRoute(c *gin.Context) {
+	abortWithError(c, http.StatusNotFound, codeRouteNotFound, "Route `" + c.Request.URL.Path + "` not found")
+}
+
+// handler for router.NoMethod
+func NoMethod(c *gin.Context) {
+	abortWithError(c, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method `" + c.Request.Method + "` is not allowed")
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"errors"
+	"testing"
+	"net/http"
+	"net/http/httptest"
+	"encoding/json"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAbortWithError(t *testing.T) {
+	// record request
+	rr := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rr)
+
+	c.Request = &http.Request {
+		Header: make(http.Header),
+	}
+	c.Request.Header.Set("X-Request-ID", "42")
+
+	// actual function call
+	abortWithError(c, http.StatusBadRequest, codeInvalidParameter, "bad")
+
+	var resp errorResponse
+
+	// convert body to `errorResponse`
+	err := json.NewDecoder(rr.Body).Decode(&resp)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, true, c.IsAborted())
+	assert.Equal(t, errorBody{ Code: codeInvalidParameter, Message: "bad", RequestID: "42" }, resp.Error)
+}
+
+func TestInternalError(t *testing.T) {
+	// record request
+	rr := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rr)
+
+	c.Request = &http.Request {
+		Header: make(http.Header),
+	}
+
+	// actual function call
+	internalError(c, errors.New("connection refused"))
+
+	var resp errorResponse
+
+	// convert body to `errorResponse`
+	err := json.NewDecoder(rr.Body).Decode(&resp)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, codeInternal, resp.Error.Code)
+	// underlying error is never shown to the client
+	assert.NotContains(t, resp.Error.Message, "connection refused")
+	assert.Equal(t, "connection refused", c.Errors.Last().Error())
+}
+
+func TestNoRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// record request
+	rr := httptest.NewRecorder()
+
+	// test router
+	router := gin.Default()
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(NoRoute)
+	router.NoMethod(NoMethod)
+	router.GET("/posts", func(c *gin.Context) {})
+
+	for _, tc := range []struct {
+		method	string
+		path	string
+		code	string
+		status	int
+	}{
+		{ http.MethodGet, "/nope", codeRouteNotFound, http.StatusNotFound },
+		{ http.MethodPut, "/posts", codeMethodNotAllowed, http.StatusMethodNotAllowed },
+	} {
+		rr = httptest.NewRecorder()
+
+		// mock request
+		request, err := http.NewRequest(tc.method, tc.path, nil)
+		assert.NoError(t, err)
+
+		// make request
+		router.ServeHTTP(rr, request)
+
+		var resp errorResponse
+
+		// convert body to `errorResponse`
+		err = json.NewDecoder(rr.Body).Decode(&resp)
+		assert.NoError(t, err)
+
+		assert.Equal(t, tc.status, rr.Code)
+		assert.Equal(t, tc.code, resp.Error.Code)
+	}
+}
@@ -1,12 +1,97 @@
 package middleware
 
 import (
+	"time"
+	"context"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
+const defaultReadinessTimeout = time.Second
+
+// result of a single dependency probe
+type dependencyStatus struct {
+	Status		string	`json:"status"`
+	LatencyMS	float64	`json:"latency_ms"`
+	Error		string	`json:"error,omitempty"`
+}
+
+// kept for older probes, prefer /livez and /readyz
 func (h *Controller) GetHealthz(c *gin.Context) {
+	// let load balancer stop routing here before connections are closed
+	if h.isShuttingDown() {
+		c.String(http.StatusServiceUnavailable, "Service is shutting down. " + h.Cfg.ServiceVersion.String())
+		return
+	}
+
 	c.String(http.StatusOK, "Service is ready. " + h.Cfg.ServiceVersion.String())
 }
 
+// process is up and able to serve HTTP, dependencies are not checked
+func (h *Controller) GetLivez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H {
+		"status": "alive",
+		"version": h.Cfg.ServiceVersion.String(),
+	})
+}
+
+// dependencies the service can not work without, none with in-memory storage
+func (h *Controller) probes() map[string]func(ctx context.Context) error {
+	probes := map[string]func(ctx context.Context) error {}
+	if h.DB != nil {
+		probes["postgres"] = h.DB.PingContext
+	}
+	return probes
+}
+
+func probe(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) dependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := fn(ctx)
+	status := dependencyStatus{
+		Status: "up",
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+
+	if err != nil {
+		status.Status = "down"
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// service is able to handle requests: not shutting down and every dependency is up
+func (h *Controller) GetReadyz(c *gin.Context) {
+	version := h.Cfg.ServiceVersion.String()
+
+	if h.isShuttingDown() {
+		c.JSON(http.StatusServiceUnavailable, gin.H {
+			"status": "shutting_down",
+			"version": version,
+		})
+		return
+	}
+
+	timeout := h.ReadinessTimeout
+	if timeout == 0 {
+		timeout = defaultReadinessTimeout
+	}
+
+	code, status := http.StatusOK, "ready"
+	checks := make(map[string]dependencyStatus)
+	for name, fn := range h.probes() {
+		checks[name] = probe(c.Request.Context(), timeout, fn)
+		if checks[name].Status != "up" {
+			code, status = http.StatusServiceUnavailable, "not_ready"
+		}
+	}
+
+	c.JSON(code, gin.H {
+		"status": status,
+		"version": version,
+		"checks": checks,
+	})
+}
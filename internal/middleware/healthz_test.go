@@ -1,12 +1,15 @@
 package middleware
 
 import (
+	"errors"
 	"testing"
 	"net/http"
 	"net/http/httptest"
+	"encoding/json"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/gin-gonic/gin"
+	"github.com/DATA-DOG/go-sqlmock"
 
 	"feed-service/internal/models"
 )
@@ -41,3 +44,196 @@ func TestGetHealthz(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rr.Code)
 	assert.Equal(t, "Service is ready. " + cfg.ServiceVersion.String(), rr.Body.String())
 }
+
+func TestGetHealthzShuttingDown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Mock init
+	cfg := models.Config {
+		ServiceVersion: "Test",
+	}
+	ctrl := Controller{
+		Cfg: &cfg,
+		DB: nil,
+	}
+	ctrl.SetShuttingDown()
+
+	// record request
+	rr := httptest.NewRecorder()
+
+	// test router
+	router := gin.Default()
+	router.GET("/healthz", ctrl.GetHealthz)
+
+	// mock request
+	request, err := http.NewRequest(http.MethodGet, "/healthz", nil)
+	assert.NoError(t, err)
+
+	// make request
+	router.ServeHTTP(rr, request)
+
+	// expect 503 with `Service is shutting down. Test`
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "Service is shutting down. " + cfg.ServiceVersion.String(), rr.Body.String())
+}
+
+type readyzResponse struct {
+	Status		string						`json:"status"`
+	Version		string						`json:"version"`
+	Checks		map[string]dependencyStatus	`json:"checks"`
+}
+
+func TestGetLivez(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Mock init
+	cfg := models.Config {
+		ServiceVersion: "Test",
+	}
+	ctrl := Controller{
+		Cfg: &cfg,
+		DB: nil,
+	}
+
+	// record request
+	rr := httptest.NewRecorder()
+
+	// test router
+	router := gin.Default()
+	router.GET("/livez", ctrl.GetLivez)
+
+	// mock request
+	request, err := http.NewRequest(http.MethodGet, "/livez", nil)
+	assert.NoError(t, err)
+
+	// make request
+	router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"status": "alive", "version": "Test"}`, rr.Body.String())
+}
+
+func TestGetReadyz(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		name	string
+		pingErr	error
+		code	int
+		status	string
+		dep		string
+	}{
+		{ "ready", nil, http.StatusOK, "ready", "up" },
+		{ "db down", errors.New("connection refused"), http.StatusServiceUnavailable, "not_ready", "down" },
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			// Mock init
+			db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+			assert.NoError(t, err)
+			defer db.Close()
+
+			cfg := models.Config {
+				ServiceVersion: "Test",
+			}
+			ctrl := Controller{
+				Cfg: &cfg,
+				DB: db,
+			}
+
+			mock.ExpectPing().WillReturnError(tc.pingErr)
+
+			// record request
+			rr := httptest.NewRecorder()
+
+			// test router
+			router := gin.Default()
+			router.GET("/readyz", ctrl.GetReadyz)
+
+			// mock request
+			request, err := http.NewRequest(http.MethodGet, "/readyz", nil)
+			assert.NoError(t, err)
+
+			// make request
+			router.ServeHTTP(rr, request)
+
+			var resp readyzResponse
+
+			// convert body to `readyzResponse`
+			err = json.NewDecoder(rr.Body).Decode(&resp)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.code, rr.Code)
+			assert.Equal(t, tc.status, resp.Status)
+			assert.Equal(t, "Test", resp.Version)
+			assert.Equal(t, tc.dep, resp.Checks["postgres"].Status)
+			if tc.pingErr != nil {
+				assert.Equal(t, tc.pingErr.Error(), resp.Checks["postgres"].Error)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetReadyzShuttingDown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Mock init, DB is not expected to be touched
+	cfg := models.Config {
+		ServiceVersion: "Test",
+	}
+	ctrl := Controller{
+		Cfg: &cfg,
+		DB: nil,
+	}
+	ctrl.SetShuttingDown()
+
+	// record request
+	rr := httptest.NewRecorder()
+
+	// test router
+	router := gin.Default()
+	router.GET("/readyz", ctrl.GetReadyz)
+
+	// mock request
+	request, err := http.NewRequest(http.MethodGet, "/readyz", nil)
+	assert.NoError(t, err)
+
+	// make request
+	router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.JSONEq(t, `{"status": "shutting_down", "version": "Test"}`, rr.Body.String())
+}
+
+func TestGetReadyzWithoutDB(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// STORAGE=memory, nothing to probe
+	cfg := models.Config {
+		ServiceVersion: "Test",
+	}
+	ctrl := Controller{
+		Cfg: &cfg,
+		DB: nil,
+	}
+
+	// record request
+	rr := httptest.NewRecorder()
+
+	// test router
+	router := gin.Default()
+	router.GET("/readyz", ctrl.GetReadyz)
+
+	// mock request
+	request, err := http.NewRequest(http.MethodGet, "/readyz", nil)
+	assert.NoError(t, err)
+
+	// make request
+	router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"status": "ready", "version": "Test", "checks": {}}`, rr.Body.String())
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"io"
+	"fmt"
+	"time"
+	"log/slog"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	requestIDHeader	= "X-Request-ID"
+	// gin.Context key holding id set by RequestID
+	requestIDKey	= "request_id"
+	// longer client ids are replaced, they end up in every log line
+	maxRequestIDLength	= 128
+)
+
+// client id is passed through as is, so it must be safe to log and echo back
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID keeps `X-Request-ID` of the client or generates one,
+// the id is returned in the response header and attached to logs and errors
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// Logger writes a line per request, errors recorded by handlers
+// (see internalError) are logged with the request they belong to
+func Logger(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("request_id", requestID(c)),
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if id, ok := identity(c); ok {
+			attrs = append(attrs, slog.String("user_id", id.UserID))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.Any("errors", c.Errors.Errors()))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Recovery turns panics into internal_error responses, panic and stack trace
+// are logged by Logger, so it has to be installed before Recovery
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered interface{}) {
+		internalError(c, fmt.Errorf("panic: %v\n%s", recovered, debug.Stack()))
+	})
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"encoding/json"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/fail", func(c *gin.Context) {
+		invalidParameter(c, "bad")
+	})
+
+	cases := map[string]struct {
+		header	string
+		kept	bool
+	}{
+		"client id":	{ header: "abc-42", kept: true },
+		"no id":		{ header: "" },
+		"too long":		{ header: strings.Repeat("a", maxRequestIDLength + 1) },
+		"unsafe":		{ header: "abc\tdef" },
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/fail", nil)
+			if tc.header != "" {
+				req.Header.Set(requestIDHeader, tc.header)
+			}
+			router.ServeHTTP(rr, req)
+
+			var resp errorResponse
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+
+			id := rr.Header().Get(requestIDHeader)
+			assert.NotEmpty(t, id)
+			assert.Equal(t, id, resp.Error.RequestID)
+			if tc.kept {
+				assert.Equal(t, tc.header, id)
+			} else {
+				assert.NotEqual(t, tc.header, id)
+			}
+		})
+	}
+}
+
+func testLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, nil))
+}
+
+func TestLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var buf bytes.Buffer
+	router := gin.New()
+	router.Use(RequestID(), Logger(testLogger(&buf)), asUser("penny"))
+	router.GET("/posts/:uuid", func(c *gin.Context) {
+		internalError(c, errors.New("connection refused"))
+	})
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/posts/42", nil)
+	req.Header.Set(requestIDHeader, "abc-42")
+	router.ServeHTTP(rr, req)
+
+	var line map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+
+	assert.Equal(t, "ERROR", line["level"])
+	assert.Equal(t, "abc-42", line["request_id"])
+	assert.Equal(t, "/posts/:uuid", line["route"])
+	assert.Equal(t, "/posts/42", line["path"])
+	assert.Equal(t, float64(http.StatusInternalServerError), line["status"])
+	assert.Equal(t, "penny", line["user_id"])
+	// never shown to the client, but has to be in logs
+	assert.Equal(t, []interface{}{"connection refused"}, line["errors"])
+}
+
+func TestLoggerLevel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var buf bytes.Buffer
+	router := gin.New()
+	router.Use(Logger(testLogger(&buf)))
+	router.GET("/ok", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.NoRoute(NoRoute)
+
+	cases := map[string]string{
+		"/ok":		"INFO",
+		"/nope":	"WARN",
+	}
+
+	for path, level := range cases {
+		buf.Reset()
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(rr, req)
+
+		var line map[string]interface{}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+		assert.Equal(t, level, line["level"], path)
+		assert.NotContains(t, line, "errors")
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var buf bytes.Buffer
+	router := gin.New()
+	router.Use(RequestID(), Logger(testLogger(&buf)), Recovery())
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/panic", nil)
+	router.ServeHTTP(rr, req)
+
+	var resp errorResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, codeInternal, resp.Error.Code)
+	assert.NotContains(t, resp.Error.Message, "boom")
+
+	var line map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, resp.Error.RequestID, line["request_id"])
+	assert.Contains(t, line["errors"].([]interface{})[0], "panic: boom")
+}
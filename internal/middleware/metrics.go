@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"time"
+	"strconv"
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"feed-service/internal/models"
+)
+
+const metricsNamespace = "feed"
+
+// Metrics exported on /metrics. Methods are safe to call on nil *Metrics,
+// so handlers do not care whether metrics are enabled
+type Metrics struct {
+	registry			*prometheus.Registry
+
+	requests			*prometheus.CounterVec
+	requestDuration		*prometheus.HistogramVec
+	postsCreated		prometheus.Counter
+	reactions			*prometheus.CounterVec
+}
+
+// metrics on a dedicated registry, db pool stats are exported if db is not nil
+func NewMetrics(db *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name: "http_requests_total",
+			Help: "HTTP requests by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name: "http_request_duration_seconds",
+			Help: "HTTP request latency by route and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		postsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name: "posts_created_total",
+			Help: "Posts published.",
+		}),
+		reactions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name: "reactions_total",
+			Help: "Reactions changed by users: like, dislike or clear. Repeated ones are not counted.",
+		}, []string{"reaction"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.postsCreated,
+		m.reactions,
+	)
+
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, "postgres"))
+	}
+	return m
+}
+
+// gin middleware recording every request
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		if m == nil {
+			return
+		}
+
+		// route template, not the path, keeps label cardinality bounded
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		m.requests.WithLabelValues(route, c.Request.Method, strconv.Itoa(c.Writer.Status())).Inc()
+		m.requestDuration.WithLabelValues(route, c.Request.Method).Observe(time.Since(start).Seconds())
+	}
+}
+
+// handler for /metrics, answers as an unknown route when metrics are disabled
+func (m *Metrics) Handler() gin.HandlerFunc {
+	if m == nil {
+		return NoRoute
+	}
+	return gin.WrapH(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+}
+
+func (m *Metrics) postCreated() {
+	if m == nil {
+		return
+	}
+	m.postsCreated.Inc()
+}
+
+func (m *Metrics) reacted(reaction models.Reaction) {
+	if m == nil {
+		return
+	}
+
+	label := string(reaction)
+	if reaction == models.ReactionNone {
+		label = "clear"
+	}
+	m.reactions.WithLabelValues(label).Inc()
+}
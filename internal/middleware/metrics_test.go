@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"testing"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/gin-gonic/gin"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"feed-service/internal/models"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Mock init
+	db, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	m := NewMetrics(db)
+
+	// test router
+	router := gin.Default()
+	router.Use(m.Middleware())
+	router.GET("/posts/:uuid", func(c *gin.Context) {
+		c.Status(http.StatusTeapot)
+	})
+	router.GET("/metrics", m.Handler())
+
+	for _, path := range []string{"/posts/1", "/posts/2", "/nope"} {
+		// record request
+		rr := httptest.NewRecorder()
+
+		// mock request
+		request, err := http.NewRequest(http.MethodGet, path, nil)
+		assert.NoError(t, err)
+
+		// make request
+		router.ServeHTTP(rr, request)
+	}
+
+	// paths are folded into route template
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.requests.WithLabelValues("/posts/:uuid", "GET", "418")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requests.WithLabelValues("unmatched", "GET", "404")))
+
+	// record request
+	rr := httptest.NewRecorder()
+
+	// mock request
+	request, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+	assert.NoError(t, err)
+
+	// make request
+	router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "feed_http_request_duration_seconds_bucket")
+	assert.Contains(t, rr.Body.String(), "go_sql_open_connections{db_name=\"postgres\"}")
+}
+
+func TestMetricsBusinessCounters(t *testing.T) {
+	m := NewMetrics(nil)
+
+	m.postCreated()
+	m.reacted(models.ReactionLike)
+	m.reacted(models.ReactionLike)
+	m.reacted(models.ReactionDislike)
+	m.reacted(models.ReactionNone)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.postsCreated))
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.reactions.WithLabelValues("like")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.reactions.WithLabelValues("dislike")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.reactions.WithLabelValues("clear")))
+}
+
+// handlers are used without metrics in most tests
+func TestMetricsNil(t *testing.T) {
+	var m *Metrics
+
+	assert.NotPanics(t, func() {
+		m.postCreated()
+		m.reacted(models.ReactionLike)
+	})
+
+	// test router
+	router := gin.New()
+	router.GET("/metrics", m.Handler())
+
+	rr := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+	assert.NoError(t, err)
+	router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+// middleware goes before Recovery, so panics are counted as 500
+func TestMetricsPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := NewMetrics(nil)
+
+	// test router
+	router := gin.New()
+	router.Use(m.Middleware(), Recovery())
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	rr := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/panic", nil)
+	assert.NoError(t, err)
+	router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requests.WithLabelValues("/panic", "GET", "500")))
+}
+
+// only reactions that changed something are counted
+func TestReactMetrics(t *testing.T) {
+	for _, unchanged := range []bool{false, true} {
+		store := &fakeStore{ unchanged: unchanged }
+		ctrl := &Controller{ Store: store, Metrics: NewMetrics(nil) }
+
+		rr := serve(t, ctrl.PostLike, "penny", http.MethodPost, "/like", "/like?uuid=" + testUUID, nil)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		expected := 1.0
+		if unchanged {
+			expected = 0
+		}
+		assert.Equal(t, expected, testutil.ToFloat64(ctrl.Metrics.reactions.WithLabelValues("like")), unchanged)
+	}
+}
@@ -2,10 +2,11 @@ package middleware
 
 import (
 	"errors"
+	"context"
 	"strings"
 	"strconv"
+	"time"
 	"net/http"
-	"database/sql"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -13,7 +14,7 @@ import (
 	"feed-service/internal/models"
 )
 
-type newPostRequestBody struct {
+type postRequestBody struct {
 	Content		string		`json:"content"`
 }
 
@@ -22,125 +23,307 @@ func isValidUUID(u string) bool {
 	return err == nil
 }
 
-func singleTransaction(h *Controller, c *gin.Context, queryString string, params ...interface{}) {
-	tx, err := h.DB.Begin()
+// context of a single store call, done once the client goes away or QueryTimeout passes
+func (h *Controller) queryContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	if h.QueryTimeout <= 0 {
+		return context.WithCancel(c.Request.Context())
+	}
+	return context.WithTimeout(c.Request.Context(), h.QueryTimeout)
+}
+
+// responds with 404 for models.ErrPostNotFound, with 403 for models.ErrNotAuthor,
+// see queryError for the rest
+func storeError(c *gin.Context, err error, u string) {
+	switch {
+	case errors.Is(err, models.ErrPostNotFound):
+		postNotFound(c, u)
+	case errors.Is(err, models.ErrNotAuthor):
+		abortWithError(c, http.StatusForbidden, codeForbidden, "Only the author can change post `" + u + "`")
+	default:
+		queryError(c, err)
+	}
+}
+
+// responds with 504 if the query deadline has passed, with 503 if the query was canceled
+// and with 500 for anything else
+func queryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		// `_ =` to silence lint, returned value is the same error
+		_ = c.Error(err)
+		abortWithError(c, http.StatusGatewayTimeout, codeTimeout, "Request timed out")
+	case errors.Is(err, context.Canceled):
+		// client has most likely gone already, the status is for logs and metrics
+		_ = c.Error(err)
+		abortWithError(c, http.StatusServiceUnavailable, codeUnavailable, "Request canceled")
+	default:
+		internalError(c, err)
+	}
+}
+
+// reads page size from `limit`, `last` is kept as its older alias
+func parseLimit(c *gin.Context) (limit uint64, ok bool, err error) {
+	for _, key := range []string{"limit", "last"} {
+		queryLimitString, found := c.GetQuery(key)
+		if !found {
+			continue
+		}
+
+		limit, err = strconv.ParseUint(queryLimitString, 10, 32)
+		if err != nil {
+			return 0, false, errors.New("Parameter `" + key + "` is invalid.\n`" + key + "`=" + queryLimitString)
+		}
+		return limit, true, nil
+	}
+	return 0, false, nil
+}
+
+// reads RFC 3339 timestamp from query parameter `key`
+func parseTime(c *gin.Context, key string) (t time.Time, ok bool, err error) {
+	queryTimeString, found := c.GetQuery(key)
+	if !found {
+		return t, false, nil
+	}
+
+	t, err = time.Parse(time.RFC3339, queryTimeString)
 	if err != nil {
-		c.AbortWithStatus(http.StatusInternalServerError)
+		return t, false, errors.New("Parameter `" + key + "` is invalid.\n`" + key + "`=" + queryTimeString)
+	}
+	return t, true, nil
+}
+
+func (h *Controller) GetPosts(c *gin.Context) {
+	listPosts(h, c, models.ListOptions{})
+}
+
+func (h *Controller) GetUserPosts(c *gin.Context) {
+	author := c.Param("id")
+	if strings.TrimSpace(author) == "" {
+		invalidParameter(c, "Provide valid `id` parameter")
 		return
 	}
 
-	stmt, err := tx.Prepare(queryString)
+	listPosts(h, c, models.ListOptions{ AuthorID: author })
+}
+
+// responds with a page of the feed, query parameters narrow opts down
+func listPosts(h *Controller, c *gin.Context, opts models.ListOptions) {
+	// newest posts first by default
+	order := c.DefaultQuery("order", string(models.OrderDesc))
+	if order != string(models.OrderAsc) && order != string(models.OrderDesc) {
+		invalidParameter(c, "Parameter `order` is invalid.\n`order`=" + order)
+		return
+	}
+	opts.Order = models.SortOrder(order)
+
+	switch sort := models.PostSort(c.DefaultQuery("sort", string(models.SortNew))); sort {
+	case models.SortNew, models.SortTop, models.SortControversial, models.SortHot:
+		opts.Sort = sort
+	default:
+		invalidParameter(c, "Parameter `sort` is invalid.\n`sort`=" + string(sort))
+		return
+	}
+
+	// cursor of one sort does not page through another
+	p, err := parsePage(c, string(opts.Sort))
 	if err != nil {
-		// `_ =` to silence lint, no way to react to this
-		_ = tx.Rollback()
-		c.AbortWithStatus(http.StatusInternalServerError)
+		invalidParameter(c, err.Error())
 		return
 	}
-	defer stmt.Close()
+	opts.Since, opts.Until, opts.Limit = p.since, p.until, p.storeLimit()
+	if p.after != nil {
+		opts.After = &models.PostPosition{ Score: p.after.Score, CreatedAt: p.after.CreatedAt, UUID: p.after.UUID }
+	}
+
+	ctx, cancel := h.queryContext(c)
+	defer cancel()
 
-	_, err = stmt.Exec(params...)
+	posts, err := h.Store.List(ctx, opts)
 	if err != nil {
-		// `_ =` to silence lint, no way to react to this
-		_ = tx.Rollback()
-		c.AbortWithStatus(http.StatusInternalServerError)
+		queryError(c, err)
+		return
+	}
+
+	writePage(c, p, posts, func(post *models.Post) cursor {
+		return cursorFromPost(post, opts.Sort)
+	})
+}
+
+func (h *Controller) GetPost(c *gin.Context) {
+	u := c.Param("uuid")
+	if !isValidUUID(u) {
+		invalidParameter(c, "Provide valid `uuid` parameter")
 		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		// `_ =` to silence lint, no way to react to this
-		_ = tx.Rollback()
-		c.AbortWithStatus(http.StatusInternalServerError)
+	ctx, cancel := h.queryContext(c)
+	defer cancel()
+
+	post, err := h.Store.Get(ctx, u)
+	if err != nil {
+		storeError(c, err, u)
 		return
 	}
 
-	c.Status(http.StatusOK)
+	c.JSON(http.StatusOK, post)
 }
 
-func (h *Controller) GetPosts(c *gin.Context) {
-	queryString := "SELECT uuid, content, likes, dislikes FROM posts"
-
-	if queryLimitString, ok := c.GetQuery("last"); ok {
-		if _, err := strconv.ParseUint(queryLimitString, 10, 64); err == nil {
-			queryString += " LIMIT " + queryLimitString
-		} else {
-			c.String(http.StatusBadRequest, "Parameter `last` is invalid.\n`last`=" + queryLimitString)
-			return
-		}
+// sets reaction of the current user to the post from `uuid` parameter,
+// ReactionNone clears it. Responds with updated counters
+func react(h *Controller, c *gin.Context, reaction models.Reaction) {
+	u, ok := c.GetQuery("uuid")
+	if !ok || !isValidUUID(u) {
+		invalidParameter(c, "Provide valid `uuid` parameter")
+		return
 	}
 
-	rows, err := h.DB.Query(queryString)
-	switch {
-	// should return empty response with 200 StatusOK
-	// REASON: could be SELECT on empty table
-	case err == sql.ErrNoRows:
-		c.JSON(http.StatusOK, gin.H { "total": 0, "data": []models.Post{} })
+	// route is expected to be behind Auth
+	id, ok := identity(c)
+	if !ok {
+		unauthorized(c, "Authentication required")
 		return
-	case err != nil:
-		c.AbortWithStatus(http.StatusInternalServerError)
+	}
+
+	ctx, cancel := h.queryContext(c)
+	defer cancel()
+
+	post, changed, err := h.Store.React(ctx, u, id.UserID, reaction)
+	if err != nil {
+		storeError(c, err, u)
 		return
 	}
-	defer rows.Close()
 
-	posts := make([]models.Post, 0, 32)
-	for rows.Next() {
-		post := models.Post{}
-		err = rows.Scan(&post.UUID, &post.Content, &post.Likes, &post.Dislikes)
-		if err != nil {
-			c.AbortWithStatus(http.StatusInternalServerError)
-			return
-		}
-		posts = append(posts, post)
+	// repeated like is a no-op and is not counted
+	if changed {
+		h.Metrics.reacted(reaction)
 	}
 
-	response := gin.H {
-		"data": posts,
-		"total":len(posts),
+	var current interface{}
+	if reaction != models.ReactionNone {
+		current = reaction
 	}
 
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, gin.H {
+		"uuid": u,
+		"likes": post.Likes,
+		"dislikes": post.Dislikes,
+		"reaction": current,
+	})
 }
 
 func (h *Controller) PostLike(c *gin.Context) {
-	queryString := "UPDATE posts SET likes = likes + 1 WHERE uuid = $1;"
+	react(h, c, models.ReactionLike)
+}
 
-	u, ok := c.GetQuery("uuid")
-	if !ok || !isValidUUID(u) {
-		c.String(http.StatusBadRequest, "Provide valid `uuid` parameter")
-		return
+func (h *Controller) PostDislike(c *gin.Context) {
+	react(h, c, models.ReactionDislike)
+}
+
+func (h *Controller) PostClearReaction(c *gin.Context) {
+	react(h, c, models.ReactionNone)
+}
+
+// reads post content from JSON body, responds with 400 on invalid one
+func bindContent(c *gin.Context) (content string, ok bool) {
+	var req postRequestBody
+
+	err := c.ShouldBindJSON(&req);
+	if  err != nil{
+		abortWithError(c, http.StatusBadRequest, codeInvalidBody, err.Error())
+		return "", false
 	}
 
-	singleTransaction(h, c, queryString, u)
+	trimmed := strings.TrimSpace(req.Content)
+	if trimmed == "" {
+		abortWithError(c, http.StatusBadRequest, codeEmptyContent, "Content must not be empty")
+		return "", false
+	}
+	return trimmed, true
 }
 
-func (h *Controller) PostDislike(c *gin.Context) {
-	queryString := "UPDATE posts SET dislikes = dislikes + 1 WHERE uuid = $1;"
+func (h *Controller) PostNewPost(c *gin.Context) {
+	// route is expected to be behind Auth
+	id, ok := identity(c)
+	if !ok {
+		unauthorized(c, "Authentication required")
+		return
+	}
 
-	u, ok := c.GetQuery("uuid")
-	if !ok || !isValidUUID(u) {
-		c.String(http.StatusBadRequest, "Provide valid `uuid` parameter")
+	content, ok := bindContent(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := h.queryContext(c)
+	defer cancel()
+
+	post, err := h.Store.Create(ctx, id.UserID, content)
+	if err != nil {
+		queryError(c, err)
 		return
 	}
 
-	singleTransaction(h, c, queryString, u)
+	h.Metrics.postCreated()
+
+	c.Header("Location", "/posts/" + post.UUID)
+	c.JSON(http.StatusCreated, post)
 }
 
-func (h *Controller) PostNewPost(c *gin.Context) {
-	queryString := "INSERT INTO posts(content) VALUES ($1);"
+// changes content of the post, only its author is allowed to
+func (h *Controller) PatchPost(c *gin.Context) {
+	u := c.Param("uuid")
+	if !isValidUUID(u) {
+		invalidParameter(c, "Provide valid `uuid` parameter")
+		return
+	}
 
-	var req newPostRequestBody
+	// route is expected to be behind Auth
+	id, ok := identity(c)
+	if !ok {
+		unauthorized(c, "Authentication required")
+		return
+	}
 
-	err := c.BindJSON(&req);
-	if  err != nil{
-		// `_ =` to silence lint, no way to react to this
-		_ = c.AbortWithError(http.StatusBadRequest, err)
+	content, ok := bindContent(c)
+	if !ok {
 		return
 	}
 
-	trimmed := strings.TrimSpace(req.Content)
-	if trimmed == "" {
-		// `_ =` to silence lint, no way to react to this
-		_ = c.AbortWithError(http.StatusBadRequest, errors.New("empty content"))
+	ctx, cancel := h.queryContext(c)
+	defer cancel()
+
+	post, err := h.Store.Update(ctx, u, id.UserID, content)
+	if err != nil {
+		storeError(c, err, u)
 		return
 	}
-	singleTransaction(h, c, queryString, trimmed)
+
+	c.JSON(http.StatusOK, post)
+}
+
+// marks post as deleted, row is kept so operators are able to restore it.
+// Only the author is allowed to
+func (h *Controller) DeletePost(c *gin.Context) {
+	u := c.Param("uuid")
+	if !isValidUUID(u) {
+		invalidParameter(c, "Provide valid `uuid` parameter")
+		return
+	}
+
+	// route is expected to be behind Auth
+	id, ok := identity(c)
+	if !ok {
+		unauthorized(c, "Authentication required")
+		return
+	}
+
+	ctx, cancel := h.queryContext(c)
+	defer cancel()
+
+	if err := h.Store.Delete(ctx, u, id.UserID); err != nil {
+		storeError(c, err, u)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
 }
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"sync"
+	"math"
+	"time"
+	"strconv"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"feed-service/internal/models"
+)
+
+// RateLimiter keeps token buckets of clients. In-memory one limits each replica
+// on its own, a shared backend (e.g. Redis) can be plugged in to limit the cluster as a whole
+type RateLimiter interface {
+	// takes a token from key's bucket, retryAfter tells when the next one
+	// is available if there are none
+	Allow(key string, limit models.RateLimit) (allowed bool, retryAfter time.Duration)
+}
+
+type bucket struct {
+	tokens		float64
+	updated		time.Time
+	limit		models.RateLimit
+}
+
+// refills bucket up to now
+func (b *bucket) refill(now time.Time) {
+	rate := float64(b.limit.Requests) / b.limit.Per.Seconds()
+	b.tokens = math.Min(float64(b.limit.Requests), b.tokens + now.Sub(b.updated).Seconds() * rate)
+	b.updated = now
+}
+
+// MemoryRateLimiter is a RateLimiter local to the process
+type MemoryRateLimiter struct {
+	mu			sync.Mutex
+	buckets		map[string]*bucket
+	lastSweep	time.Time
+	// replaced in tests
+	now			func() time.Time
+}
+
+// full buckets are dropped this often, they are the same as missing ones
+const rateLimiterSweepInterval = time.Minute
+
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		buckets: make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now: time.Now,
+	}
+}
+
+func (l *MemoryRateLimiter) Allow(key string, limit models.RateLimit) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= rateLimiterSweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok || b.limit != limit {
+		// new client or limit changed, start with a full bucket
+		b = &bucket{ tokens: float64(limit.Requests), updated: now, limit: limit }
+		l.buckets[key] = b
+	}
+	b.refill(now)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	rate := float64(limit.Requests) / limit.Per.Seconds()
+	return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// keeps memory bounded by active clients
+func (l *MemoryRateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Requests) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// RateLimit rejects requests over limit with 429 and `Retry-After`.
+// Clients are told apart by user id if authenticated (so Auth goes first), by IP otherwise.
+// Routes sharing name share buckets. Disabled limit lets everything through
+func RateLimit(limiter RateLimiter, name string, limit models.RateLimit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limit.Enabled() {
+			c.Next()
+			return
+		}
+
+		client := "ip:" + c.ClientIP()
+		if id, ok := identity(c); ok {
+			client = "user:" + id.UserID
+		}
+
+		allowed, retryAfter := limiter.Allow(name + ":" + client, limit)
+		if !allowed {
+			// header is in whole seconds, rounding down would invite an early retry
+			seconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+			c.Header("Retry-After", seconds)
+			abortWithError(c, http.StatusTooManyRequests, codeRateLimited, "Too many requests, retry in " + seconds + "s")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"time"
+	"testing"
+	"net/http"
+	"net/http/httptest"
+	"encoding/json"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/gin-gonic/gin"
+
+	"feed-service/internal/models"
+)
+
+// limiter with a clock moved by hand
+func testRateLimiter() (*MemoryRateLimiter, *time.Time) {
+	now := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	l := NewMemoryRateLimiter()
+	l.lastSweep = now
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestMemoryRateLimiter(t *testing.T) {
+	l, now := testRateLimiter()
+	limit := models.RateLimit{ Requests: 2, Per: time.Minute }
+
+	// burst
+	for i := 0; i < 2; i++ {
+		allowed, _ := l.Allow("penny", limit)
+		assert.True(t, allowed)
+	}
+
+	allowed, retryAfter := l.Allow("penny", limit)
+	assert.False(t, allowed)
+	assert.Equal(t, 30 * time.Second, retryAfter)
+
+	// other clients have their own buckets
+	allowed, _ = l.Allow("leonard", limit)
+	assert.True(t, allowed)
+
+	// a token per 30s
+	*now = now.Add(20 * time.Second)
+	allowed, retryAfter = l.Allow("penny", limit)
+	assert.False(t, allowed)
+	assert.Equal(t, 10 * time.Second, retryAfter)
+
+	*now = now.Add(10 * time.Second)
+	allowed, _ = l.Allow("penny", limit)
+	assert.True(t, allowed)
+}
+
+func TestMemoryRateLimiterSweep(t *testing.T) {
+	l, now := testRateLimiter()
+	limit := models.RateLimit{ Requests: 2, Per: time.Hour }
+
+	l.Allow("penny", limit)
+	l.Allow("leonard", limit)
+	l.Allow("leonard", limit)
+
+	// penny's bucket is full again, leonard's is not
+	*now = now.Add(30 * time.Minute)
+	l.Allow("sheldon", limit)
+
+	assert.NotContains(t, l.buckets, "penny")
+	assert.Contains(t, l.buckets, "leonard")
+	assert.Contains(t, l.buckets, "sheldon")
+}
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	l, _ := testRateLimiter()
+	limit := models.RateLimit{ Requests: 1, Per: 90 * time.Second }
+
+	router := gin.New()
+	router.POST("/penny", asUser("penny"), RateLimit(l, "test", limit), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.POST("/leonard", asUser("leonard"), RateLimit(l, "test", limit), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.POST("/anonymous", RateLimit(l, "test", limit), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	post := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, path, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusOK, post("/penny").Code)
+
+	rr := post("/penny")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "90", rr.Header().Get("Retry-After"))
+
+	var resp errorResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, codeRateLimited, resp.Error.Code)
+
+	// same IP, but another user
+	assert.Equal(t, http.StatusOK, post("/leonard").Code)
+	// anonymous requests are limited by IP
+	assert.Equal(t, http.StatusOK, post("/anonymous").Code)
+	assert.Equal(t, http.StatusTooManyRequests, post("/anonymous").Code)
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	l, _ := testRateLimiter()
+
+	router := gin.New()
+	router.POST("/", RateLimit(l, "test", models.RateLimit{}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for i := 0; i < 10; i++ {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/", nil)
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+	assert.Empty(t, l.buckets)
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"feed-service/internal/models"
+)
+
+// longer queries are rejected, they are expensive to parse and match
+const maxSearchQueryLength = 256
+
+// responds with a page of posts matching `q`, best matches first.
+// Paginated the same way as the feed: `limit`, `after` and `next_cursor`
+func (h *Controller) SearchPosts(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		invalidParameter(c, "Provide non-empty `q` parameter")
+		return
+	}
+	if utf8.RuneCountInString(q) > maxSearchQueryLength {
+		invalidParameter(c, "Parameter `q` is longer than " + strconv.Itoa(maxSearchQueryLength) + " characters")
+		return
+	}
+	opts := models.SearchOptions{ Query: q }
+
+	p, err := parsePage(c, cursorSearch)
+	if err != nil {
+		invalidParameter(c, err.Error())
+		return
+	}
+	opts.Since, opts.Until, opts.Limit = p.since, p.until, p.storeLimit()
+	if p.after != nil {
+		opts.After = &models.SearchPosition{ Rank: p.after.Rank, CreatedAt: p.after.CreatedAt, UUID: p.after.UUID }
+	}
+
+	ctx, cancel := h.queryContext(c)
+	defer cancel()
+
+	results, err := h.Store.Search(ctx, opts)
+	if err != nil {
+		queryError(c, err)
+		return
+	}
+
+	writePage(c, p, results, cursorFromResult)
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"strings"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"encoding/json"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/gin-gonic/gin"
+
+	"feed-service/internal/models"
+)
+
+type searchPage struct {
+	Total		int						`json:"total"`
+	Data		[]models.SearchResult	`json:"data"`
+	NextCursor	*string					`json:"next_cursor"`
+}
+
+var mockResult = models.SearchResult {
+	Post: mockPost,
+	Rank: 0.0607927,
+	Snippet: "<b>simple</b> text",
+}
+
+var mockResult2 = models.SearchResult {
+	Post: mockPost2,
+	Rank: 0.0303964,
+	Snippet: "hard text",
+}
+
+// GET path served by router with search and post routes, as in main
+func searchPosts(t *testing.T, store *fakeStore, path string) (*httptest.ResponseRecorder, searchPage) {
+	ctrl := Controller{
+		Store: store,
+	}
+
+	// set up test router, static segment has to win over `:uuid`
+	router := gin.New()
+	router.GET("/posts/search", ctrl.SearchPosts)
+	router.GET("/posts/:uuid", ctrl.GetPost)
+
+	// make request
+	rr := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, path, nil)
+	assert.NoError(t, err)
+	router.ServeHTTP(rr, request)
+
+	var p searchPage
+	if rr.Code == http.StatusOK {
+		// convert body to `searchPage`
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+	}
+	return rr, p
+}
+
+func TestSearchPostsOK(t *testing.T) {
+	store := &fakeStore{ results: []models.SearchResult{mockResult, mockResult2} }
+
+	rr, p := searchPosts(t, store, "/posts/search?q=%20simple%20text%20")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 2, p.Total)
+	assert.EqualValues(t, []models.SearchResult{mockResult, mockResult2}, p.Data)
+	assert.Nil(t, p.NextCursor)
+	assert.Equal(t, []storeCall{{ "Search", []interface{}{models.SearchOptions{ Query: "simple text" }} }}, store.calls)
+}
+
+func TestSearchPostsEmpty(t *testing.T) {
+	store := &fakeStore{ results: []models.SearchResult{} }
+
+	rr, p := searchPosts(t, store, "/posts/search?q=nothing")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 0, p.Total)
+	assert.EqualValues(t, []models.SearchResult{}, p.Data)
+}
+
+// next page continues after rank, creation time and uuid of the last result
+func TestSearchPostsNextCursor(t *testing.T) {
+	store := &fakeStore{ results: []models.SearchResult{mockResult, mockResult2} }
+
+	rr, p := searchPosts(t, store, "/posts/search?q=text&limit=1")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 1, p.Total)
+	assert.Equal(t, 2, store.calls[0].args[0].(models.SearchOptions).Limit)
+	if assert.NotNil(t, p.NextCursor) {
+		assert.Equal(t, encodeCursor(cursorFromResult(&mockResult)), *p.NextCursor)
+	}
+
+	store.calls = nil
+	rr, _ = searchPosts(t, store, "/posts/search?q=text&limit=1&after=" + *p.NextCursor)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, &models.SearchPosition{
+		Rank: mockResult.Rank,
+		CreatedAt: mockResult.CreatedAt,
+		UUID: mockResult.UUID,
+	}, store.calls[0].args[0].(models.SearchOptions).After)
+}
+
+func TestSearchPostsBadParam(t *testing.T) {
+	cases := []struct {
+		name	string
+		path	string
+	}{
+		{ "no q", "/posts/search" },
+		{ "blank q", "/posts/search?q=%20%20" },
+		{ "long q", "/posts/search?q=" + strings.Repeat("a", maxSearchQueryLength + 1) },
+		{ "bad limit", "/posts/search?q=text&limit=-1" },
+		{ "bad since", "/posts/search?q=text&since=yesterday" },
+		{ "bad cursor", "/posts/search?q=text&after=asd" },
+		{ "feed cursor", "/posts/search?q=text&after=" + encodeCursor(cursorFromPost(&mockPost, models.SortNew)) },
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			store := &fakeStore{}
+
+			rr, _ := searchPosts(t, store, tc.path)
+
+			var resp errorResponse
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.Equal(t, codeInvalidParameter, resp.Error.Code)
+			assert.Empty(t, store.calls)
+		})
+	}
+}
+
+func TestSearchPostsStoreErr(t *testing.T) {
+	store := &fakeStore{ err: errStore }
+
+	rr, _ := searchPosts(t, store, "/posts/search?q=text")
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
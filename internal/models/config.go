@@ -2,21 +2,363 @@ package models
 
 import (
 	"os"
+	"fmt"
+	"log"
+	"bytes"
+	"errors"
+	"context"
+	"encoding"
+	"log/slog"
+	"time"
+	"sort"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 type EnvVar string
 
+// storage backends selected with STORAGE
+const (
+	StoragePostgres	= "postgres"
+	StorageMemory	= "memory"
+)
+
+// PostgresConfig is the part of Config needed to reach the database,
+// loaded on its own by `feed-service migrate`.
+// Connection fields are required by validate, not by tags, STORAGE=memory needs none of them
+type PostgresConfig struct {
+	PostgresUser		EnvVar	`env:"POSTGRES_USER"`
+	PostgresPassword	EnvVar	`env:"POSTGRES_PASSWORD"`
+	PostgresDBName		EnvVar	`env:"POSTGRES_DBNAME"`
+	PostgresHost		EnvVar	`env:"POSTGRES_HOST"`
+	PostgresPort		int		`env:"POSTGRES_PORT" default:"5432"`
+	PostgresSSLMode		EnvVar	`env:"POSTGRES_SSLMODE" default:"disable"`
+	PostgresSSLRootCert	EnvVar	`env:"POSTGRES_SSLROOTCERT"`
+	PostgresSSLCert		EnvVar	`env:"POSTGRES_SSLCERT"`
+	PostgresSSLKey		EnvVar	`env:"POSTGRES_SSLKEY"`
+	PostgresConnectTimeout	time.Duration	`env:"POSTGRES_CONNECT_TIMEOUT" default:"2s"`
+	PostgresMaxOpenConns	int				`env:"POSTGRES_MAX_OPEN_CONNS"`
+	PostgresMaxIdleConns	int				`env:"POSTGRES_MAX_IDLE_CONNS"`
+	PostgresConnMaxLifetime	time.Duration	`env:"POSTGRES_CONN_MAX_LIFETIME"`
+	PostgresConnMaxIdleTime	time.Duration	`env:"POSTGRES_CONN_MAX_IDLE_TIME"`
+	PostgresRetryInitialInterval	time.Duration	`env:"POSTGRES_RETRY_INITIAL_INTERVAL" default:"500ms"`
+	PostgresRetryMaxInterval		time.Duration	`env:"POSTGRES_RETRY_MAX_INTERVAL" default:"10s"`
+	PostgresRetryMaxWait			time.Duration	`env:"POSTGRES_RETRY_MAX_WAIT" default:"1m"`
+}
+
 type Config struct {
-	PostgresUser		EnvVar
-	PostgresPassword	EnvVar
-	PostgresDBName		EnvVar
-	PostgresHost		EnvVar
-	PostgresPort		EnvVar
-	RouterHost			EnvVar
-	RouterPort			EnvVar
-	ServiceVersion		EnvVar
+	PostgresConfig
+
+	Storage				EnvVar	`env:"STORAGE" default:"postgres"`
+	RouterHost			EnvVar	`env:"ROUTER_HOST"`
+	RouterPort			int		`env:"ROUTER_PORT" default:"8080"`
+	ServiceVersion		EnvVar	`env:"SERVICE_VERSION" default:"dev"`
+	JWTSecret			EnvVar	`env:"JWT_SECRET,required"`
+	MigrateOnStart		bool	`env:"MIGRATE_ON_START" default:"true"`
+	ShutdownDelay		time.Duration	`env:"SHUTDOWN_DELAY"`
+	ShutdownTimeout		time.Duration	`env:"SHUTDOWN_TIMEOUT" default:"15s"`
+	ReadinessTimeout	time.Duration	`env:"READINESS_TIMEOUT" default:"1s"`
+	QueryTimeout		time.Duration	`env:"QUERY_TIMEOUT" default:"5s"`
+	SecretReloadInterval	time.Duration	`env:"SECRET_RELOAD_INTERVAL" default:"30s"`
+	LogLevel			slog.Level	`env:"LOG_LEVEL" default:"info"`
+	RateLimitNewPost	RateLimit	`env:"RATE_LIMIT_NEW_POST" default:"10/m"`
+	RateLimitReactions	RateLimit	`env:"RATE_LIMIT_REACTIONS" default:"60/m"`
+}
+
+// ConfigError lists every problem found by Load
+type ConfigError []string
+
+func (e ConfigError) Error() string {
+	return "invalid config:\n\t" + strings.Join(e, "\n\t")
+}
+
+// Load fills struct pointed by dst field by field from env variable named in `env` tag,
+// from file named in `<env>_FILE` env variable (Docker/Kubernetes secrets),
+// from the same key in lower case in YAML file (skipped if file is empty)
+// or from `default` tag, in that order. Fields marked `required` have no default.
+// All problems are collected into ConfigError instead of stopping at the first one
+func Load(dst interface{}, file string) error {
+	values := map[string]string{}
+	if file != "" {
+		body, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := yaml.Unmarshal(body, &values); err != nil {
+			return fmt.Errorf("config file %s: %w", file, err)
+		}
+	}
+
+	var problems ConfigError
+	failed := map[string]bool{}
+	load(reflect.ValueOf(dst).Elem(), values, &problems, failed)
+
+	// file is shared by partial loads, so keys are checked against the whole Config.
+	// Unknown key is most likely a typo, silently ignoring it would be worse
+	known := map[string]bool{}
+	fileKeys(reflect.TypeOf(Config{}), known)
+	for key := range values {
+		if !known[key] {
+			problems = append(problems, fmt.Sprintf("config file %s: unknown key %q", file, key))
+		}
+	}
+
+	// ranges are checked on the fields that did parse, so every problem is reported at once.
+	// Findings start with the key, a field that failed to parse is not reported twice
+	if v, ok := dst.(interface{ validate() []string }); ok {
+		for _, problem := range v.validate() {
+			key := problem
+			if i := strings.IndexAny(problem, ": "); i >= 0 {
+				key = problem[:i]
+			}
+			if !failed[key] {
+				problems = append(problems, problem)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// keys of struct type t in config file, embedded structs included
+func fileKeys(t reflect.Type, known map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			fileKeys(field.Type, known)
+			continue
+		}
+
+		if tag, ok := field.Tag.Lookup("env"); ok {
+			key, _, _ := strings.Cut(tag, ",")
+			known[strings.ToLower(key)] = true
+		}
+	}
+}
+
+// walks struct fields, embedded structs included. Keys with a problem are added to failed
+func load(v reflect.Value, file map[string]string, problems *ConfigError, failed map[string]bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			load(v.Field(i), file, problems, failed)
+			continue
+		}
+
+		tag, ok := field.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+		key, opts, _ := strings.Cut(tag, ",")
+		report := func(problem string) {
+			*problems = append(*problems, problem)
+			failed[key] = true
+		}
+
+		raw, found := os.LookupEnv(key)
+		if path, ok := os.LookupEnv(key + "_FILE"); ok {
+			if found {
+				report("both " + key + " and " + key + "_FILE are set")
+				continue
+			}
+
+			secret, err := ReadSecretFile(path)
+			if err != nil {
+				report(fmt.Sprintf("%s_FILE: %v", key, err))
+				continue
+			}
+			raw, found = secret, true
+		}
+		if !found {
+			raw, found = file[strings.ToLower(key)]
+		}
+		if !found {
+			raw, found = field.Tag.Lookup("default")
+		}
+		if !found {
+			if opts == "required" {
+				report(key + " is required")
+			}
+			continue
+		}
+
+		if err := setField(v.Field(i), raw); err != nil {
+			report(fmt.Sprintf("%s: %q %v", key, raw, err))
+		}
+	}
+}
+
+// ReadSecretFile returns file content without trailing newline most editors add
+func ReadSecretFile(path string) (string, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight(body, "\r\n")), nil
+}
+
+// WatchSecretFile checks file named in `<key>_FILE` env variable every interval
+// and calls onChange once its content differs from current, until ctx is done.
+// Returns at once if the variable is not set or interval is not positive.
+// Content is compared rather than mtime, mounted Kubernetes secrets are replaced via symlink swap
+func WatchSecretFile(ctx context.Context, key string, current string, interval time.Duration, onChange func(string)) {
+	path, ok := os.LookupEnv(key + "_FILE")
+	if !ok || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		secret, err := ReadSecretFile(path)
+		if err != nil {
+			// mid-rotation or gone, keep the last value
+			log.Printf("config: reading %s_FILE: %v", key, err)
+			continue
+		}
+		// truncated before being rewritten, an empty secret is never valid
+		if strings.TrimSpace(secret) == "" {
+			log.Printf("config: %s_FILE %s is empty, keeping the last value", key, path)
+			continue
+		}
+		if secret != current {
+			current = secret
+			log.Printf("config: %s reloaded from %s", key, path)
+			onChange(secret)
+		}
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setField(f reflect.Value, raw string) error {
+	if u, ok := f.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(raw)); err != nil {
+			return errors.New("is not valid: " + err.Error())
+		}
+		return nil
+	}
+
+	switch {
+	case f.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return errors.New("is not a duration")
+		}
+		f.SetInt(int64(d))
+	case f.Kind() == reflect.String:
+		f.SetString(raw)
+	case f.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return errors.New("is not an int")
+		}
+		f.SetInt(int64(n))
+	case f.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("is not a bool")
+		}
+		f.SetBool(b)
+	default:
+		return fmt.Errorf("has unsupported type %v", f.Type())
+	}
+	return nil
+}
+
+func validPort(key string, port int) []string {
+	if port < 1 || port > 65535 {
+		return []string{fmt.Sprintf("%s: %d is not a valid port", key, port)}
+	}
+	return nil
+}
+
+func notNegative(key string, n int64) []string {
+	if n < 0 {
+		return []string{key + " must not be negative"}
+	}
+	return nil
+}
+
+func (c *PostgresConfig) validate() []string {
+	var problems []string
+
+	for key, v := range map[string]EnvVar{
+		"POSTGRES_USER": c.PostgresUser,
+		"POSTGRES_PASSWORD": c.PostgresPassword,
+		"POSTGRES_DBNAME": c.PostgresDBName,
+		"POSTGRES_HOST": c.PostgresHost,
+	} {
+		if v == "" {
+			problems = append(problems, key + " is required")
+		}
+	}
+	// map order is random, keep messages stable
+	sort.Strings(problems)
+
+	problems = append(problems, validPort("POSTGRES_PORT", c.PostgresPort)...)
+	switch c.PostgresSSLMode {
+	case "disable", "require", "verify-ca", "verify-full":
+	default:
+		problems = append(problems, fmt.Sprintf("POSTGRES_SSLMODE: %q is not one of disable, require, verify-ca, verify-full", c.PostgresSSLMode))
+	}
+
+	problems = append(problems, notNegative("POSTGRES_CONNECT_TIMEOUT", int64(c.PostgresConnectTimeout))...)
+	problems = append(problems, notNegative("POSTGRES_MAX_OPEN_CONNS", int64(c.PostgresMaxOpenConns))...)
+	problems = append(problems, notNegative("POSTGRES_MAX_IDLE_CONNS", int64(c.PostgresMaxIdleConns))...)
+	problems = append(problems, notNegative("POSTGRES_CONN_MAX_LIFETIME", int64(c.PostgresConnMaxLifetime))...)
+	problems = append(problems, notNegative("POSTGRES_CONN_MAX_IDLE_TIME", int64(c.PostgresConnMaxIdleTime))...)
+	problems = append(problems, notNegative("POSTGRES_RETRY_MAX_INTERVAL", int64(c.PostgresRetryMaxInterval))...)
+	problems = append(problems, notNegative("POSTGRES_RETRY_MAX_WAIT", int64(c.PostgresRetryMaxWait))...)
+
+	// zero interval would retry in a busy loop
+	if c.PostgresRetryMaxWait > 0 && c.PostgresRetryInitialInterval <= 0 {
+		problems = append(problems, "POSTGRES_RETRY_INITIAL_INTERVAL must be positive when POSTGRES_RETRY_MAX_WAIT is set")
+	}
+	return problems
+}
+
+func (c *Config) validate() []string {
+	var problems []string
+
+	switch c.Storage {
+	case StoragePostgres:
+		problems = append(problems, c.PostgresConfig.validate()...)
+	case StorageMemory:
+	default:
+		problems = append(problems, fmt.Sprintf("STORAGE: %q is not one of postgres, memory", c.Storage))
+	}
+
+	problems = append(problems, validPort("ROUTER_PORT", c.RouterPort)...)
+	if c.JWTSecret == "" {
+		problems = append(problems, "JWT_SECRET must not be empty")
+	}
+
+	problems = append(problems, notNegative("SHUTDOWN_DELAY", int64(c.ShutdownDelay))...)
+	problems = append(problems, notNegative("SHUTDOWN_TIMEOUT", int64(c.ShutdownTimeout))...)
+	if c.ReadinessTimeout <= 0 {
+		problems = append(problems, "READINESS_TIMEOUT must be positive")
+	}
+	problems = append(problems, notNegative("SECRET_RELOAD_INTERVAL", int64(c.SecretReloadInterval))...)
+	problems = append(problems, notNegative("QUERY_TIMEOUT", int64(c.QueryTimeout))...)
+	return problems
 }
 
+// kept for compatibility, config is read by Load
 func (ev *EnvVar) GetEnv(key string) {
 	if val, ok := os.LookupEnv(key); ok {
 		*ev = EnvVar(val)
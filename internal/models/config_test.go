@@ -3,6 +3,10 @@ package models
 import (
 	"testing"
 	"os"
+	"time"
+	"context"
+	"log/slog"
+	"path/filepath"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -31,3 +35,288 @@ func TestGetEnv(t *testing.T) {
 		assert.Panics(t, func() { e.GetEnv(key2) }, "`BUZZ` is not supposed to be set")
 	})
 }
+
+// every key is set, so tests do not depend on the environment of the runner
+func setConfigEnv(t *testing.T, env map[string]string) {
+	for _, key := range []string{
+		"POSTGRES_USER", "POSTGRES_PASSWORD", "POSTGRES_DBNAME", "POSTGRES_HOST", "POSTGRES_PORT",
+		"POSTGRES_SSLMODE", "POSTGRES_CONNECT_TIMEOUT", "POSTGRES_MAX_OPEN_CONNS",
+		"ROUTER_HOST", "ROUTER_PORT", "JWT_SECRET", "MIGRATE_ON_START", "SHUTDOWN_DELAY",
+		"POSTGRES_PASSWORD_FILE", "JWT_SECRET_FILE", "LOG_LEVEL", "STORAGE", "QUERY_TIMEOUT",
+	} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	for key, val := range env {
+		t.Setenv(key, val)
+	}
+}
+
+func writeConfigFile(t *testing.T, body string) string {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(body), 0o600))
+	return file
+}
+
+func TestLoad(t *testing.T) {
+	setConfigEnv(t, map[string]string{
+		"POSTGRES_USER":		"feed",
+		"POSTGRES_PASSWORD":	"secret",
+		"POSTGRES_DBNAME":		"feed",
+		"JWT_SECRET":			"jwt",
+		// env wins over file
+		"ROUTER_PORT":			"9090",
+	})
+	file := writeConfigFile(t, `
+postgres_host: db
+postgres_port: 6432
+postgres_connect_timeout: 5s
+router_port: 8000
+migrate_on_start: false
+log_level: debug
+`)
+
+	var cfg Config
+	assert.NoError(t, Load(&cfg, file))
+
+	assert.Equal(t, EnvVar("feed"), cfg.PostgresUser)
+	assert.Equal(t, EnvVar("db"), cfg.PostgresHost)
+	assert.Equal(t, 6432, cfg.PostgresPort)
+	assert.Equal(t, 5 * time.Second, cfg.PostgresConnectTimeout)
+	assert.Equal(t, 9090, cfg.RouterPort)
+	assert.False(t, cfg.MigrateOnStart)
+	assert.Equal(t, slog.LevelDebug, cfg.LogLevel)
+	// defaults
+	assert.Equal(t, EnvVar("disable"), cfg.PostgresSSLMode)
+	assert.Equal(t, 15 * time.Second, cfg.ShutdownTimeout)
+	assert.Equal(t, 5 * time.Second, cfg.QueryTimeout)
+	// optional
+	assert.Equal(t, EnvVar(""), cfg.RouterHost)
+}
+
+func TestLoadPartial(t *testing.T) {
+	setConfigEnv(t, map[string]string{
+		"POSTGRES_USER":		"feed",
+		"POSTGRES_PASSWORD":	"secret",
+		"POSTGRES_DBNAME":		"feed",
+		"POSTGRES_HOST":		"db",
+	})
+	// keys of the rest of Config are fine, JWT_SECRET is not required
+	file := writeConfigFile(t, "router_port: 8000\n")
+
+	var cfg PostgresConfig
+	assert.NoError(t, Load(&cfg, file))
+	assert.Equal(t, 5432, cfg.PostgresPort)
+}
+
+func TestLoadErrors(t *testing.T) {
+	setConfigEnv(t, map[string]string{
+		"POSTGRES_PASSWORD":		"secret",
+		"POSTGRES_PORT":			"five",
+		"POSTGRES_CONNECT_TIMEOUT":	"2",
+		"MIGRATE_ON_START":			"maybe",
+		"LOG_LEVEL":				"loud",
+		"READINESS_TIMEOUT":		"0s",
+	})
+	file := writeConfigFile(t, "postgres_hots: db\n")
+
+	var cfg Config
+	err := Load(&cfg, file)
+
+	var problems ConfigError
+	assert.ErrorAs(t, err, &problems)
+	assert.ElementsMatch(t, ConfigError{
+		`POSTGRES_PORT: "five" is not an int`,
+		`POSTGRES_CONNECT_TIMEOUT: "2" is not a duration`,
+		"JWT_SECRET is required",
+		`MIGRATE_ON_START: "maybe" is not a bool`,
+		`LOG_LEVEL: "loud" is not valid: slog: level string "loud": unknown name`,
+		`config file ` + file + `: unknown key "postgres_hots"`,
+		// ranges of parsed fields are checked along with parse problems,
+		// POSTGRES_PORT and JWT_SECRET are not reported again
+		"POSTGRES_DBNAME is required",
+		"POSTGRES_HOST is required",
+		"POSTGRES_USER is required",
+		"READINESS_TIMEOUT must be positive",
+	}, problems)
+}
+
+func TestLoadValidate(t *testing.T) {
+	setConfigEnv(t, map[string]string{
+		"POSTGRES_USER":			"feed",
+		"POSTGRES_PASSWORD":		"secret",
+		"POSTGRES_DBNAME":			"feed",
+		"POSTGRES_HOST":			"db",
+		"POSTGRES_SSLMODE":			"prefer",
+		"POSTGRES_MAX_OPEN_CONNS":	"-1",
+		"ROUTER_PORT":				"70000",
+		"JWT_SECRET":				"",
+		"SHUTDOWN_DELAY":			"-1s",
+		"QUERY_TIMEOUT":			"-5s",
+	})
+
+	var cfg Config
+	err := Load(&cfg, "")
+
+	var problems ConfigError
+	assert.ErrorAs(t, err, &problems)
+	assert.ElementsMatch(t, ConfigError{
+		`POSTGRES_SSLMODE: "prefer" is not one of disable, require, verify-ca, verify-full`,
+		"POSTGRES_MAX_OPEN_CONNS must not be negative",
+		"ROUTER_PORT: 70000 is not a valid port",
+		"JWT_SECRET must not be empty",
+		"SHUTDOWN_DELAY must not be negative",
+		"QUERY_TIMEOUT must not be negative",
+	}, problems)
+}
+
+func TestLoadStorage(t *testing.T) {
+	t.Run("postgres needs connection", func(t *testing.T) {
+		setConfigEnv(t, map[string]string{
+			"POSTGRES_PASSWORD":	"secret",
+			"JWT_SECRET":			"key",
+		})
+
+		var cfg Config
+		err := Load(&cfg, "")
+
+		var problems ConfigError
+		assert.ErrorAs(t, err, &problems)
+		assert.Equal(t, ConfigError{
+			"POSTGRES_DBNAME is required",
+			"POSTGRES_HOST is required",
+			"POSTGRES_USER is required",
+		}, problems)
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		setConfigEnv(t, map[string]string{
+			"STORAGE":		"memory",
+			"JWT_SECRET":	"key",
+		})
+
+		var cfg Config
+		assert.NoError(t, Load(&cfg, ""))
+		assert.Equal(t, EnvVar(StorageMemory), cfg.Storage)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		setConfigEnv(t, map[string]string{
+			"STORAGE":		"redis",
+			"JWT_SECRET":	"key",
+		})
+
+		var cfg Config
+		err := Load(&cfg, "")
+
+		var problems ConfigError
+		assert.ErrorAs(t, err, &problems)
+		assert.Equal(t, ConfigError{`STORAGE: "redis" is not one of postgres, memory`}, problems)
+	})
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	var cfg Config
+	assert.Error(t, Load(&cfg, filepath.Join(t.TempDir(), "nope.yaml")))
+}
+
+func TestLoadSecretFile(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "password")
+	assert.NoError(t, os.WriteFile(secret, []byte("s3cret\n"), 0o600))
+
+	setConfigEnv(t, map[string]string{
+		"POSTGRES_USER":			"feed",
+		"POSTGRES_PASSWORD_FILE":	secret,
+		"POSTGRES_DBNAME":			"feed",
+		"POSTGRES_HOST":			"db",
+	})
+
+	var cfg PostgresConfig
+	assert.NoError(t, Load(&cfg, ""))
+	assert.Equal(t, EnvVar("s3cret"), cfg.PostgresPassword)
+}
+
+func TestLoadSecretFileErrors(t *testing.T) {
+	setConfigEnv(t, map[string]string{
+		"POSTGRES_USER":			"feed",
+		"POSTGRES_PASSWORD":		"secret",
+		"POSTGRES_PASSWORD_FILE":	"/run/secrets/password",
+		"POSTGRES_DBNAME":			"feed",
+		"POSTGRES_HOST":			"db",
+		"JWT_SECRET_FILE":			filepath.Join(t.TempDir(), "nope"),
+	})
+
+	var cfg Config
+	err := Load(&cfg, "")
+
+	var problems ConfigError
+	assert.ErrorAs(t, err, &problems)
+	assert.Len(t, problems, 2)
+	assert.Equal(t, "both POSTGRES_PASSWORD and POSTGRES_PASSWORD_FILE are set", problems[0])
+	assert.Contains(t, problems[1], "JWT_SECRET_FILE: ")
+}
+
+func TestWatchSecretFile(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "password")
+	assert.NoError(t, os.WriteFile(secret, []byte("old"), 0o600))
+	t.Setenv("WATCHED_FILE", secret)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan string, 1)
+	done := make(chan struct{})
+	go func() {
+		WatchSecretFile(ctx, "WATCHED", "old", time.Millisecond, func(s string) { changes <- s })
+		close(done)
+	}()
+
+	assert.NoError(t, os.WriteFile(secret, []byte("new\n"), 0o600))
+	select {
+	case s := <-changes:
+		assert.Equal(t, "new", s)
+	case <-time.After(time.Second):
+		t.Fatal("change is not noticed")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watcher did not stop")
+	}
+}
+
+// empty file is skipped, the next real secret is picked up
+func TestWatchSecretFileEmpty(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "password")
+	assert.NoError(t, os.WriteFile(secret, []byte("old"), 0o600))
+	t.Setenv("WATCHED_FILE", secret)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan string, 1)
+	go WatchSecretFile(ctx, "WATCHED", "old", time.Millisecond, func(s string) { changes <- s })
+
+	assert.NoError(t, os.WriteFile(secret, []byte(" \n"), 0o600))
+	select {
+	case s := <-changes:
+		t.Fatalf("empty secret %q is applied", s)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	assert.NoError(t, os.WriteFile(secret, []byte("new"), 0o600))
+	select {
+	case s := <-changes:
+		assert.Equal(t, "new", s)
+	case <-time.After(time.Second):
+		t.Fatal("change is not noticed")
+	}
+}
+
+func TestWatchSecretFileNotSet(t *testing.T) {
+	os.Unsetenv("UNWATCHED_FILE")
+
+	// returns at once instead of blocking until ctx is done
+	WatchSecretFile(context.Background(), "UNWATCHED", "", time.Millisecond, func(string) {
+		t.Error("nothing to watch")
+	})
+}
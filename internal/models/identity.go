@@ -0,0 +1,6 @@
+package models
+
+// Identity of an authenticated user making the request
+type Identity struct {
+	UserID		string
+}
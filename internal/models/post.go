@@ -1,9 +1,19 @@
 package models
 
+import (
+	"time"
+)
+
 // Post represents single post in feed
 type Post struct {
-	UUID		string	`json:"uuid"`
-	Content		string	`json:"content"`
-	Likes		uint	`json:"likes"`
-	Dislikes	uint	`json:"dislikes"`
+	UUID		string		`json:"uuid"`
+	// empty for posts published before authorship was tracked
+	AuthorID	string		`json:"author_id"`
+	Content		string		`json:"content"`
+	Likes		uint		`json:"likes"`
+	Dislikes	uint		`json:"dislikes"`
+	CreatedAt	time.Time	`json:"created_at"`
+	UpdatedAt	time.Time	`json:"updated_at"`
+	// score the page is sorted by (see ScoreBy), set by PostStore.List for sorts other than SortNew
+	Score		float64		`json:"-"`
 }
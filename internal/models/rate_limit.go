@@ -0,0 +1,51 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit allows Requests per Per to a client, with bursts up to Requests.
+// Zero value means no limit
+type RateLimit struct {
+	Requests	int
+	Per			time.Duration
+}
+
+func (l RateLimit) Enabled() bool {
+	return l.Requests > 0 && l.Per > 0
+}
+
+// UnmarshalText parses `<requests>/<period>`, e.g. `10/m`, `100/1h` or `5/30s`,
+// `off` disables the limit
+func (l *RateLimit) UnmarshalText(text []byte) error {
+	s := string(text)
+	if s == "off" {
+		*l = RateLimit{}
+		return nil
+	}
+
+	requestsString, perString, found := strings.Cut(s, "/")
+	if !found {
+		return errors.New("expected <requests>/<period> or off")
+	}
+
+	requests, err := strconv.Atoi(requestsString)
+	if err != nil || requests <= 0 {
+		return errors.New("requests must be a positive int")
+	}
+
+	// `m` reads better than `1m`
+	if perString != "" && (perString[0] < '0' || perString[0] > '9') {
+		perString = "1" + perString
+	}
+	per, err := time.ParseDuration(perString)
+	if err != nil || per <= 0 {
+		return errors.New("period must be a positive duration")
+	}
+
+	*l = RateLimit{ Requests: requests, Per: per }
+	return nil
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitUnmarshalText(t *testing.T) {
+	valid := map[string]RateLimit{
+		"10/m":		{ Requests: 10, Per: time.Minute },
+		"100/1h":	{ Requests: 100, Per: time.Hour },
+		"5/30s":	{ Requests: 5, Per: 30 * time.Second },
+		"off":		{},
+	}
+
+	for text, expected := range valid {
+		var l RateLimit
+		assert.NoError(t, l.UnmarshalText([]byte(text)), text)
+		assert.Equal(t, expected, l, text)
+	}
+
+	for _, text := range []string{"", "10", "10/", "/m", "0/m", "-1/m", "ten/m", "10/0s", "10/-1m", "10/parsec"} {
+		var l RateLimit
+		assert.Error(t, l.UnmarshalText([]byte(text)), text)
+	}
+}
+
+func TestRateLimitEnabled(t *testing.T) {
+	assert.True(t, RateLimit{ Requests: 1, Per: time.Second }.Enabled())
+	assert.False(t, RateLimit{}.Enabled())
+}
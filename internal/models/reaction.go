@@ -0,0 +1,10 @@
+package models
+
+// Reaction is an opinion of a single user about a post
+type Reaction string
+
+const (
+	ReactionNone	Reaction	= ""
+	ReactionLike	Reaction	= "like"
+	ReactionDislike	Reaction	= "dislike"
+)
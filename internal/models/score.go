@@ -0,0 +1,85 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// PostSort is the key a feed page is sorted by
+type PostSort string
+
+const (
+	// creation time
+	SortNew				PostSort	= "new"
+	// TopScore
+	SortTop				PostSort	= "top"
+	// ControversialScore
+	SortControversial	PostSort	= "controversial"
+	// HotScore
+	SortHot				PostSort	= "hot"
+)
+
+// same constants are used by `post_*_score` functions in migrations, keep them in sync
+const (
+	// z for 95% confidence
+	wilsonZ		= 1.96
+	// 2022-07-01T00:00:00Z, keeps hot scores small
+	hotEpoch	= 1656633600
+	// every hotPeriod of age is worth ten times the votes
+	hotPeriod	= 45000
+)
+
+// TopScore is the lower bound of Wilson score interval for the share of likes:
+// a post with 90 likes of 100 is above the one with 1 like of 1
+func TopScore(likes uint, dislikes uint) float64 {
+	n := float64(likes + dislikes)
+	if n == 0 {
+		return 0
+	}
+
+	p := float64(likes) / n
+	z2 := wilsonZ * wilsonZ
+	return (p + z2 / (2 * n) - wilsonZ * math.Sqrt((p * (1 - p) + z2 / (4 * n)) / n)) / (1 + z2 / n)
+}
+
+// ControversialScore grows with the number of votes and with how evenly they are split,
+// zero if nobody disagrees
+func ControversialScore(likes uint, dislikes uint) float64 {
+	if likes == 0 || dislikes == 0 {
+		return 0
+	}
+
+	balance := float64(min(likes, dislikes)) / float64(max(likes, dislikes))
+	return math.Pow(float64(likes + dislikes), balance)
+}
+
+// HotScore is log10 of the vote difference plus creation time in hotPeriod units.
+// It does not depend on the current time, so it can be stored, yet newer posts outrank
+// older ones unless those have collected many more votes
+func HotScore(likes uint, dislikes uint, createdAt time.Time) float64 {
+	s := float64(likes) - float64(dislikes)
+	sign := 0.0
+	switch {
+	case s > 0:
+		sign = 1
+	case s < 0:
+		sign = -1
+	}
+
+	// fractions of a second count, as in `extract(epoch FROM created_at)`
+	age := float64(createdAt.UnixNano()) / 1e9 - hotEpoch
+	return sign * math.Log10(math.Max(math.Abs(s), 1)) + age / hotPeriod
+}
+
+// Score of p by sort, zero for SortNew
+func (p *Post) ScoreBy(sort PostSort) float64 {
+	switch sort {
+	case SortTop:
+		return TopScore(p.Likes, p.Dislikes)
+	case SortControversial:
+		return ControversialScore(p.Likes, p.Dislikes)
+	case SortHot:
+		return HotScore(p.Likes, p.Dislikes, p.CreatedAt)
+	}
+	return 0
+}
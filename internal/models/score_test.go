@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopScore(t *testing.T) {
+	assert.Equal(t, 0.0, TopScore(0, 0))
+	assert.InDelta(t, 0.2065, TopScore(1, 0), 1e-4)
+	assert.InDelta(t, 0.8256, TopScore(90, 10), 1e-4)
+
+	// more votes with the same share is more certain
+	assert.Greater(t, TopScore(90, 10), TopScore(9, 1))
+	// single like is not enough to beat a well liked post
+	assert.Greater(t, TopScore(90, 10), TopScore(1, 0))
+	assert.Greater(t, TopScore(1, 0), TopScore(0, 1))
+}
+
+func TestControversialScore(t *testing.T) {
+	assert.Equal(t, 0.0, ControversialScore(0, 0))
+	assert.Equal(t, 0.0, ControversialScore(100, 0))
+	assert.Equal(t, 0.0, ControversialScore(0, 100))
+	assert.InDelta(t, 20.0, ControversialScore(10, 10), 1e-9)
+
+	// symmetric, even split and more votes score higher
+	assert.Equal(t, ControversialScore(10, 5), ControversialScore(5, 10))
+	assert.Greater(t, ControversialScore(10, 10), ControversialScore(15, 5))
+	assert.Greater(t, ControversialScore(100, 100), ControversialScore(10, 10))
+}
+
+func TestHotScore(t *testing.T) {
+	epoch := time.Unix(hotEpoch, 0)
+
+	assert.Equal(t, 0.0, HotScore(0, 0, epoch))
+	assert.InDelta(t, 2.0, HotScore(101, 1, epoch), 1e-9)
+	assert.InDelta(t, -1.0, HotScore(0, 10, epoch), 1e-9)
+	assert.InDelta(t, 1.0, HotScore(0, 0, epoch.Add(hotPeriod * time.Second)), 1e-9)
+
+	// ten times the votes are worth one hotPeriod of age
+	later := epoch.Add(2 * hotPeriod * time.Second)
+	assert.Greater(t, HotScore(1, 0, later), HotScore(10, 0, epoch))
+	assert.Less(t, HotScore(1, 0, later), HotScore(1000, 0, epoch))
+}
+
+func TestScoreBy(t *testing.T) {
+	p := Post{ Likes: 9, Dislikes: 1, CreatedAt: time.Unix(hotEpoch, 0) }
+
+	assert.Equal(t, 0.0, p.ScoreBy(SortNew))
+	assert.Equal(t, TopScore(9, 1), p.ScoreBy(SortTop))
+	assert.Equal(t, ControversialScore(9, 1), p.ScoreBy(SortControversial))
+	assert.Equal(t, HotScore(9, 1, p.CreatedAt), p.ScoreBy(SortHot))
+}
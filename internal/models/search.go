@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// SearchPosition is the sort key of a search result, pages continue right after it
+type SearchPosition struct {
+	Rank		float32
+	CreatedAt	time.Time
+	UUID		string
+}
+
+// SearchOptions select a page of posts matching Query, best matches first,
+// ties are broken by creation time and uuid, newest first. Zero values mean no filter
+type SearchOptions struct {
+	// words to look for, Postgres store also understands `"phrase"`, `or` and `-word`
+	Query		string
+	// created at or after
+	Since		time.Time
+	// created before
+	Until		time.Time
+	After		*SearchPosition
+	// max number of posts
+	Limit		int
+}
+
+// SearchResult is a post matching the query
+type SearchResult struct {
+	Post
+	// relevance, comparable within one query only
+	Rank		float32		`json:"rank"`
+	// fragment of content with matched words wrapped in <b></b>, HTML escaped
+	Snippet		string		`json:"snippet"`
+}
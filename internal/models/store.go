@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+	"errors"
+	"context"
+)
+
+// ErrPostNotFound is returned by PostStore for missing and deleted posts
+var ErrPostNotFound = errors.New("post not found")
+
+// ErrNotAuthor is returned by PostStore when a post is changed by someone else than its author
+var ErrNotAuthor = errors.New("not the author of the post")
+
+// SortOrder of a feed page by creation time
+type SortOrder string
+
+const (
+	OrderDesc	SortOrder	= "desc"
+	OrderAsc	SortOrder	= "asc"
+)
+
+// PostPosition is the sort key of a post, pages continue right after it
+type PostPosition struct {
+	// Post.Score, zero for SortNew
+	Score		float64
+	CreatedAt	time.Time
+	UUID		string
+}
+
+// ListOptions select a page of the feed. Zero values mean no filter
+type ListOptions struct {
+	AuthorID	string
+	// created at or after
+	Since		time.Time
+	// created before
+	Until		time.Time
+	// SortNew if empty, ties are broken by creation time and uuid
+	Sort		PostSort
+	// OrderDesc if empty, applies to the whole sort key
+	Order		SortOrder
+	After		*PostPosition
+	// max number of posts
+	Limit		int
+}
+
+// PostStore keeps posts and reactions to them. Deleted posts are never returned,
+// methods taking uuid report them and missing ones with ErrPostNotFound
+type PostStore interface {
+	Create(ctx context.Context, authorID string, content string) (Post, error)
+	Get(ctx context.Context, uuid string) (Post, error)
+	List(ctx context.Context, opts ListOptions) ([]Post, error)
+	Search(ctx context.Context, opts SearchOptions) ([]SearchResult, error)
+	// only the author is allowed to change the post, ErrNotAuthor otherwise
+	Update(ctx context.Context, uuid string, authorID string, content string) (Post, error)
+	// sets reaction of the user, ReactionNone clears it. Returns post with updated counters,
+	// changed is false if the user already had this reaction
+	React(ctx context.Context, uuid string, userID string, reaction Reaction) (post Post, changed bool, err error)
+	// soft delete by the author, the post is kept so operators are able to restore it
+	Delete(ctx context.Context, uuid string, authorID string) error
+}
@@ -0,0 +1,197 @@
+// Package memory keeps posts in process memory. Nothing survives a restart,
+// meant for local development and tests only
+package memory
+
+import (
+	"sort"
+	"sync"
+	"time"
+	"context"
+
+	"github.com/google/uuid"
+
+	"feed-service/internal/models"
+)
+
+type record struct {
+	post		models.Post
+	deleted		bool
+	reactions	map[string]models.Reaction
+}
+
+// PostStore is models.PostStore safe for concurrent use
+type PostStore struct {
+	mu			sync.RWMutex
+	records		map[string]*record
+	// replaced in tests
+	now			func() time.Time
+}
+
+func NewPostStore() *PostStore {
+	return &PostStore{
+		records: make(map[string]*record),
+		now: func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// live post, nil for missing and deleted ones. Caller holds the lock
+func (s *PostStore) live(u string) *record {
+	r, ok := s.records[u]
+	if !ok || r.deleted {
+		return nil
+	}
+	return r
+}
+
+func (s *PostStore) Create(_ context.Context, authorID string, content string) (models.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	r := &record{
+		post: models.Post{
+			UUID: uuid.NewString(),
+			AuthorID: authorID,
+			Content: content,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		reactions: make(map[string]models.Reaction),
+	}
+	s.records[r.post.UUID] = r
+	return r.post, nil
+}
+
+func (s *PostStore) Get(_ context.Context, u string) (models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r := s.live(u)
+	if r == nil {
+		return models.Post{}, models.ErrPostNotFound
+	}
+	return r.post, nil
+}
+
+// same order as `ORDER BY created_at, uuid` of Postgres store
+func before(a, b *models.Post) bool {
+	if a.CreatedAt.Equal(b.CreatedAt) {
+		return a.UUID < b.UUID
+	}
+	return a.CreatedAt.Before(b.CreatedAt)
+}
+
+// same order as `ORDER BY <score>, created_at, uuid`, Score is zero for SortNew
+func less(a, b *models.Post) bool {
+	if a.Score != b.Score {
+		return a.Score < b.Score
+	}
+	return before(a, b)
+}
+
+func (s *PostStore) List(_ context.Context, opts models.ListOptions) ([]models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var after *models.Post
+	if opts.After != nil {
+		after = &models.Post{ Score: opts.After.Score, CreatedAt: opts.After.CreatedAt, UUID: opts.After.UUID }
+	}
+	asc := opts.Order == models.OrderAsc
+
+	posts := make([]models.Post, 0, 32)
+	for _, r := range s.records {
+		p := r.post
+		// scores change with reactions, Postgres keeps them in generated columns, here they are computed on read
+		p.Score = p.ScoreBy(opts.Sort)
+		switch {
+		case r.deleted:
+		case opts.AuthorID != "" && p.AuthorID != opts.AuthorID:
+		case !opts.Since.IsZero() && p.CreatedAt.Before(opts.Since):
+		case !opts.Until.IsZero() && !p.CreatedAt.Before(opts.Until):
+		case after != nil && asc && !less(after, &p):
+		case after != nil && !asc && !less(&p, after):
+		default:
+			posts = append(posts, p)
+		}
+	}
+
+	sort.Slice(posts, func(i, j int) bool {
+		if asc {
+			return less(&posts[i], &posts[j])
+		}
+		return less(&posts[j], &posts[i])
+	})
+
+	if opts.Limit > 0 && len(posts) > opts.Limit {
+		posts = posts[:opts.Limit]
+	}
+	return posts, nil
+}
+
+func (s *PostStore) Update(_ context.Context, u string, authorID string, content string) (models.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.live(u)
+	if r == nil {
+		return models.Post{}, models.ErrPostNotFound
+	}
+	if r.post.AuthorID != authorID {
+		return models.Post{}, models.ErrNotAuthor
+	}
+
+	r.post.Content = content
+	r.post.UpdatedAt = s.now()
+	return r.post, nil
+}
+
+// adds delta to the counter of reaction
+func count(p *models.Post, reaction models.Reaction, delta int) {
+	switch reaction {
+	case models.ReactionLike:
+		p.Likes = uint(int(p.Likes) + delta)
+	case models.ReactionDislike:
+		p.Dislikes = uint(int(p.Dislikes) + delta)
+	}
+}
+
+func (s *PostStore) React(_ context.Context, u string, userID string, reaction models.Reaction) (models.Post, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.live(u)
+	if r == nil {
+		return models.Post{}, false, models.ErrPostNotFound
+	}
+	if r.reactions[userID] == reaction {
+		return r.post, false, nil
+	}
+
+	// counters follow reactions the way `reactions_count` trigger does
+	count(&r.post, r.reactions[userID], -1)
+	count(&r.post, reaction, 1)
+
+	if reaction == models.ReactionNone {
+		delete(r.reactions, userID)
+	} else {
+		r.reactions[userID] = reaction
+	}
+	return r.post, true, nil
+}
+
+func (s *PostStore) Delete(_ context.Context, u string, authorID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.live(u)
+	if r == nil {
+		return models.ErrPostNotFound
+	}
+	if r.post.AuthorID != authorID {
+		return models.ErrNotAuthor
+	}
+
+	r.deleted = true
+	return nil
+}
@@ -0,0 +1,285 @@
+package memory
+
+import (
+	"sync"
+	"strconv"
+	"time"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"feed-service/internal/models"
+)
+
+var t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// store with a clock that moves one minute forward on every call
+func newTestStore() *PostStore {
+	s := NewPostStore()
+	next := t0
+	s.now = func() time.Time {
+		t := next
+		next = next.Add(time.Minute)
+		return t
+	}
+	return s
+}
+
+func create(t *testing.T, s *PostStore, authorID string, content string) models.Post {
+	t.Helper()
+	post, err := s.Create(context.Background(), authorID, content)
+	require.NoError(t, err)
+	return post
+}
+
+func uuids(posts []models.Post) []string {
+	res := make([]string, 0, len(posts))
+	for _, p := range posts {
+		res = append(res, p.UUID)
+	}
+	return res
+}
+
+func TestCreateGet(t *testing.T) {
+	s := newTestStore()
+	ctx := context.Background()
+
+	post := create(t, s, "user1", "Hello")
+	assert.NotEmpty(t, post.UUID)
+	assert.Equal(t, "user1", post.AuthorID)
+	assert.Equal(t, "Hello", post.Content)
+	assert.Equal(t, t0, post.CreatedAt)
+	assert.Equal(t, t0, post.UpdatedAt)
+
+	got, err := s.Get(ctx, post.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, post, got)
+
+	_, err = s.Get(ctx, "00000000-0000-0000-0000-000000000000")
+	assert.ErrorIs(t, err, models.ErrPostNotFound)
+}
+
+func TestList(t *testing.T) {
+	s := newTestStore()
+	ctx := context.Background()
+
+	p1 := create(t, s, "user1", "1")
+	p2 := create(t, s, "user2", "2")
+	p3 := create(t, s, "user1", "3")
+	p4 := create(t, s, "user2", "4")
+	require.NoError(t, s.Delete(ctx, p4.UUID, "user2"))
+
+	tests := []struct {
+		name	string
+		opts	models.ListOptions
+		want	[]string
+	}{
+		{"all newest first", models.ListOptions{}, []string{p3.UUID, p2.UUID, p1.UUID}},
+		{"ascending", models.ListOptions{ Order: models.OrderAsc }, []string{p1.UUID, p2.UUID, p3.UUID}},
+		{"author", models.ListOptions{ AuthorID: "user1" }, []string{p3.UUID, p1.UUID}},
+		{"since", models.ListOptions{ Since: p2.CreatedAt }, []string{p3.UUID, p2.UUID}},
+		{"until", models.ListOptions{ Until: p2.CreatedAt }, []string{p1.UUID}},
+		{"limit", models.ListOptions{ Limit: 2 }, []string{p3.UUID, p2.UUID}},
+		{
+			"after descending",
+			models.ListOptions{ After: &models.PostPosition{ CreatedAt: p3.CreatedAt, UUID: p3.UUID } },
+			[]string{p2.UUID, p1.UUID},
+		},
+		{
+			"after ascending",
+			models.ListOptions{ Order: models.OrderAsc, After: &models.PostPosition{ CreatedAt: p1.CreatedAt, UUID: p1.UUID } },
+			[]string{p2.UUID, p3.UUID},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posts, err := s.List(ctx, tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, uuids(posts))
+		})
+	}
+}
+
+func TestListSort(t *testing.T) {
+	s := newTestStore()
+	ctx := context.Background()
+
+	// react as n users
+	react := func(p models.Post, reaction models.Reaction, n int) {
+		for i := 0; i < n; i++ {
+			_, _, err := s.React(ctx, p.UUID, string(reaction) + strconv.Itoa(i), reaction)
+			require.NoError(t, err)
+		}
+	}
+
+	loved := create(t, s, "user1", "loved")
+	react(loved, models.ReactionLike, 9)
+	disputed := create(t, s, "user1", "disputed")
+	react(disputed, models.ReactionLike, 5)
+	react(disputed, models.ReactionDislike, 5)
+	quiet := create(t, s, "user1", "quiet")
+
+	tests := []struct {
+		name	string
+		opts	models.ListOptions
+		want	[]string
+	}{
+		{"top", models.ListOptions{ Sort: models.SortTop }, []string{loved.UUID, disputed.UUID, quiet.UUID}},
+		{"controversial", models.ListOptions{ Sort: models.SortControversial }, []string{disputed.UUID, quiet.UUID, loved.UUID}},
+		{"controversial ascending", models.ListOptions{ Sort: models.SortControversial, Order: models.OrderAsc }, []string{loved.UUID, quiet.UUID, disputed.UUID}},
+		// minutes apart, votes decide
+		{"hot", models.ListOptions{ Sort: models.SortHot }, []string{loved.UUID, quiet.UUID, disputed.UUID}},
+		{
+			"top after cursor",
+			models.ListOptions{ Sort: models.SortTop, After: &models.PostPosition{ Score: models.TopScore(9, 0), CreatedAt: loved.CreatedAt, UUID: loved.UUID } },
+			[]string{disputed.UUID, quiet.UUID},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posts, err := s.List(ctx, tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, uuids(posts))
+		})
+	}
+}
+
+func TestListEmpty(t *testing.T) {
+	posts, err := NewPostStore().List(context.Background(), models.ListOptions{})
+	require.NoError(t, err)
+	assert.NotNil(t, posts)
+	assert.Empty(t, posts)
+}
+
+func TestListTies(t *testing.T) {
+	s := NewPostStore()
+	s.now = func() time.Time { return t0 }
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		create(t, s, "user1", "same time")
+	}
+
+	// pages of two must walk through all posts exactly once
+	var seen []string
+	opts := models.ListOptions{ Limit: 2 }
+	for {
+		page, err := s.List(ctx, opts)
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		seen = append(seen, uuids(page)...)
+		last := page[len(page) - 1]
+		opts.After = &models.PostPosition{ CreatedAt: last.CreatedAt, UUID: last.UUID }
+	}
+
+	all, err := s.List(ctx, models.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, uuids(all), seen)
+}
+
+func TestUpdate(t *testing.T) {
+	s := newTestStore()
+	ctx := context.Background()
+	post := create(t, s, "user1", "Hello")
+
+	updated, err := s.Update(ctx, post.UUID, "user1", "Bye")
+	require.NoError(t, err)
+	assert.Equal(t, "Bye", updated.Content)
+	assert.Equal(t, post.CreatedAt, updated.CreatedAt)
+	assert.True(t, updated.UpdatedAt.After(post.UpdatedAt))
+
+	require.NoError(t, s.Delete(ctx, post.UUID, "user1"))
+	_, err = s.Update(ctx, post.UUID, "user1", "Again")
+	assert.ErrorIs(t, err, models.ErrPostNotFound)
+}
+
+// post is left as it was for anyone but the author
+func TestNotAuthor(t *testing.T) {
+	s := newTestStore()
+	ctx := context.Background()
+	post := create(t, s, "alice", "Hello")
+
+	_, err := s.Update(ctx, post.UUID, "bob", "pwned")
+	assert.ErrorIs(t, err, models.ErrNotAuthor)
+	assert.ErrorIs(t, s.Delete(ctx, post.UUID, "bob"), models.ErrNotAuthor)
+
+	got, err := s.Get(ctx, post.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, post, got)
+}
+
+func TestReact(t *testing.T) {
+	s := newTestStore()
+	ctx := context.Background()
+	post := create(t, s, "user1", "Hello")
+
+	steps := []struct {
+		user		string
+		reaction	models.Reaction
+		likes		uint
+		dislikes	uint
+		changed		bool
+	}{
+		{"user2", models.ReactionLike, 1, 0, true},
+		// same reaction twice is counted once
+		{"user2", models.ReactionLike, 1, 0, false},
+		{"user3", models.ReactionDislike, 1, 1, true},
+		{"user2", models.ReactionDislike, 0, 2, true},
+		{"user3", models.ReactionNone, 0, 1, true},
+		// clearing absent reaction changes nothing
+		{"user3", models.ReactionNone, 0, 1, false},
+	}
+
+	for _, step := range steps {
+		got, changed, err := s.React(ctx, post.UUID, step.user, step.reaction)
+		require.NoError(t, err)
+		assert.Equal(t, step.likes, got.Likes, "%s %q", step.user, step.reaction)
+		assert.Equal(t, step.dislikes, got.Dislikes, "%s %q", step.user, step.reaction)
+		assert.Equal(t, step.changed, changed, "%s %q", step.user, step.reaction)
+	}
+
+	_, _, err := s.React(ctx, "00000000-0000-0000-0000-000000000000", "user2", models.ReactionLike)
+	assert.ErrorIs(t, err, models.ErrPostNotFound)
+}
+
+func TestDelete(t *testing.T) {
+	s := newTestStore()
+	ctx := context.Background()
+	post := create(t, s, "user1", "Hello")
+
+	require.NoError(t, s.Delete(ctx, post.UUID, "user1"))
+
+	_, err := s.Get(ctx, post.UUID)
+	assert.ErrorIs(t, err, models.ErrPostNotFound)
+	assert.ErrorIs(t, s.Delete(ctx, post.UUID, "user1"), models.ErrPostNotFound)
+}
+
+// meant for `go test -race`
+func TestConcurrentReactions(t *testing.T) {
+	s := NewPostStore()
+	ctx := context.Background()
+	post := create(t, s, "user1", "Hello")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _, err := s.React(ctx, post.UUID, "user" + strconv.Itoa(i), models.ReactionLike)
+			assert.NoError(t, err)
+			_, err = s.List(ctx, models.ListOptions{})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	got, err := s.Get(ctx, post.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, uint(50), got.Likes)
+}
@@ -0,0 +1,127 @@
+package memory
+
+import (
+	"html"
+	"sort"
+	"context"
+	"strings"
+	"unicode"
+
+	"feed-service/internal/models"
+)
+
+// splits text into words, keeping their offsets so matches can be highlighted
+func words(text string) (res [][2]int) {
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			res = append(res, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		res = append(res, [2]int{start, len(text)})
+	}
+	return res
+}
+
+// rank of content for terms and its HTML escaped copy with matches wrapped in <b></b>.
+// Post matches if it has every term as a whole word, case is ignored.
+// Unlike Postgres there is no stemming and no query syntax, terms are plain words
+func match(content string, terms []string) (rank float32, snippet string, ok bool) {
+	found := make(map[string]bool, len(terms))
+	wanted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	var b strings.Builder
+	last, hits := 0, 0
+	spans := words(content)
+	for _, span := range spans {
+		word := strings.ToLower(content[span[0]:span[1]])
+		if !wanted[word] {
+			continue
+		}
+		found[word] = true
+		hits++
+
+		b.WriteString(html.EscapeString(content[last:span[0]]))
+		b.WriteString("<b>" + html.EscapeString(content[span[0]:span[1]]) + "</b>")
+		last = span[1]
+	}
+	if len(found) < len(wanted) {
+		return 0, "", false
+	}
+	b.WriteString(html.EscapeString(content[last:]))
+
+	// share of matched words, shorter posts about the subject go first
+	return float32(hits) / float32(len(spans)), b.String(), true
+}
+
+// same order as `ORDER BY rank DESC, created_at DESC, uuid DESC` of Postgres store
+func better(a, b *models.SearchResult) bool {
+	if a.Rank != b.Rank {
+		return a.Rank > b.Rank
+	}
+	return before(&b.Post, &a.Post)
+}
+
+func (s *PostStore) Search(_ context.Context, opts models.SearchOptions) ([]models.SearchResult, error) {
+	var terms []string
+	for _, span := range words(opts.Query) {
+		terms = append(terms, strings.ToLower(opts.Query[span[0]:span[1]]))
+	}
+
+	var after *models.SearchResult
+	if opts.After != nil {
+		after = &models.SearchResult{
+			Post: models.Post{ CreatedAt: opts.After.CreatedAt, UUID: opts.After.UUID },
+			Rank: opts.After.Rank,
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := make([]models.SearchResult, 0, 32)
+	// nothing to look for, same as Postgres for a query of stop words only
+	if len(terms) == 0 {
+		return results, nil
+	}
+
+	for _, r := range s.records {
+		p := r.post
+		switch {
+		case r.deleted:
+			continue
+		case !opts.Since.IsZero() && p.CreatedAt.Before(opts.Since):
+			continue
+		case !opts.Until.IsZero() && !p.CreatedAt.Before(opts.Until):
+			continue
+		}
+
+		rank, snippet, ok := match(p.Content, terms)
+		if !ok {
+			continue
+		}
+		res := models.SearchResult{ Post: p, Rank: rank, Snippet: snippet }
+		if after != nil && !better(after, &res) {
+			continue
+		}
+		results = append(results, res)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return better(&results[i], &results[j])
+	})
+
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results, nil
+}
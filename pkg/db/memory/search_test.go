@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"feed-service/internal/models"
+)
+
+func TestMatch(t *testing.T) {
+	rank, snippet, ok := match("Go <3: go, GOPHER, go!", []string{"go"})
+	assert.True(t, ok)
+	assert.Equal(t, float32(3) / 5, rank)
+	assert.Equal(t, "<b>Go</b> &lt;3: <b>go</b>, GOPHER, <b>go</b>!", snippet)
+
+	_, _, ok = match("Go is fun", []string{"go", "rust"})
+	assert.False(t, ok)
+}
+
+func TestSearch(t *testing.T) {
+	s := newTestStore()
+	ctx := context.Background()
+
+	p1 := create(t, s, "user1", "Привет, мир")
+	p2 := create(t, s, "user2", "мир")
+	create(t, s, "user1", "something else")
+	p4 := create(t, s, "user2", "Мир, мир и ещё раз мир")
+	p5 := create(t, s, "user1", "мир")
+	require.NoError(t, s.Delete(ctx, p5.UUID, "user1"))
+
+	tests := []struct {
+		name	string
+		opts	models.SearchOptions
+		want	[]string
+	}{
+		// whole post matches, then 3 of 6 words, then 1 of 2
+		{"rank", models.SearchOptions{ Query: "МИР" }, []string{p2.UUID, p4.UUID, p1.UUID}},
+		{"every term", models.SearchOptions{ Query: "привет мир" }, []string{p1.UUID}},
+		{"no match", models.SearchOptions{ Query: "пока" }, []string{}},
+		{"no terms", models.SearchOptions{ Query: " ,. " }, []string{}},
+		{"limit", models.SearchOptions{ Query: "мир", Limit: 1 }, []string{p2.UUID}},
+		{"until", models.SearchOptions{ Query: "мир", Until: p2.CreatedAt }, []string{p1.UUID}},
+		{
+			"after",
+			models.SearchOptions{ Query: "мир", After: &models.SearchPosition{ Rank: 1, CreatedAt: p2.CreatedAt, UUID: p2.UUID } },
+			[]string{p4.UUID, p1.UUID},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := s.Search(ctx, tt.opts)
+			require.NoError(t, err)
+
+			got := make([]string, 0, len(results))
+			for _, r := range results {
+				got = append(got, r.UUID)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// equal ranks are ordered newest first, as in Postgres
+func TestSearchTies(t *testing.T) {
+	s := newTestStore()
+
+	older := create(t, s, "user1", "мир")
+	newer := create(t, s, "user1", "мир")
+
+	results, err := s.Search(context.Background(), models.SearchOptions{ Query: "мир" })
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, newer.UUID, results[0].UUID)
+	assert.Equal(t, older.UUID, results[1].UUID)
+	assert.Equal(t, "<b>мир</b>", results[0].Snippet)
+}
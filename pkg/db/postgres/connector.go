@@ -1,32 +1,189 @@
 package postgres
 
 import (
+	"log"
+	"math"
+	"time"
+	"math/rand"
+	"context"
+	"net/url"
+	"strconv"
 	"database/sql"
+	"database/sql/driver"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
+const (
+	defaultSSLMode			= "disable"
+	defaultConnectTimeout	= 2 * time.Second
+	// floor of retry delays, a policy without InitialInterval would hammer the server otherwise
+	minRetryInterval		= 10 * time.Millisecond
+)
+
+// RetryPolicy of the initial connection, delays grow exponentially with jitter
+type RetryPolicy struct {
+	// delay after the first failed attempt
+	InitialInterval	time.Duration
+	// delays never exceed it, 0 means no cap
+	MaxInterval		time.Duration
+	// no attempt starts later than MaxWait after the first one, 0 disables retries
+	MaxWait			time.Duration
+}
+
+// delay after `attempt` failed attempts: InitialInterval * 2^(attempt-1) capped at MaxInterval,
+// randomized to [d/2, d) by jitter in [0, 1), so replicas do not retry in lockstep
+func (p RetryPolicy) delay(attempt int, jitter float64) time.Duration {
+	d := p.InitialInterval
+	for i := 1; i < attempt && d < math.MaxInt64 / 2; i++ {
+		d *= 2
+		if p.MaxInterval > 0 && d >= p.MaxInterval {
+			d = p.MaxInterval
+			break
+		}
+	}
+	if p.MaxInterval > 0 && d > p.MaxInterval {
+		d = p.MaxInterval
+	}
+
+	return d / 2 + time.Duration(jitter * float64(d / 2))
+}
+
+// calls fn until it succeeds or policy gives up, returns the last error
+func retry(policy RetryPolicy, fn func() error) error {
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("postgres: connected on attempt %d", attempt)
+			}
+			return nil
+		}
+
+		d := max(policy.delay(attempt, rnd.Float64()), minRetryInterval)
+		if time.Since(start) + d > policy.MaxWait {
+			log.Printf("postgres: attempt %d failed: %v, giving up", attempt, err)
+			return err
+		}
+
+		log.Printf("postgres: attempt %d failed: %v, retrying in %v", attempt, err, d)
+		time.Sleep(d)
+	}
+}
+
 type PostgreSQLConfig struct {
 	User		string
 	Password	string
+	// if set, called for every new connection instead of using Password,
+	// so a rotated password is picked up without reopening the pool
+	PasswordFunc	func() string
 	DBName		string
 	Host		string
 	Port		string
+
+	// disable, require, verify-ca or verify-full. Empty means disable
+	SSLMode		string
+	// paths to PEM files, empty ones are not passed to the driver
+	SSLRootCert	string
+	SSLCert		string
+	SSLKey		string
+	// rounded up to seconds, 0 means defaultConnectTimeout
+	ConnectTimeout	time.Duration
+
+	// zero values keep database/sql defaults
+	MaxOpenConns	int
+	MaxIdleConns	int
+	ConnMaxLifetime	time.Duration
+	ConnMaxIdleTime	time.Duration
+
+	// zero value makes a single attempt
+	Retry		RetryPolicy
 }
 
-// connect to Postgres with creds from config, sslmode=disabled
-func NewPostgresDB(cfg *PostgreSQLConfig) (*sql.DB, error) {
-	connString := "postgres://" + cfg.User + ":" + cfg.Password +
-		"@" + cfg.Host + ":" + cfg.Port + "/" + cfg.DBName + "?sslmode=disable&connect_timeout=2"
+// builds lib/pq URL, credentials are escaped
+func connString(cfg *PostgreSQLConfig) string {
+	sslMode := cfg.SSLMode
+	if sslMode == "" {
+		sslMode = defaultSSLMode
+	}
+
+	timeout := cfg.ConnectTimeout
+	if timeout <= 0 {
+		timeout = defaultConnectTimeout
+	}
+	// driver accepts whole seconds only
+	timeoutSec := int64((timeout + time.Second - 1) / time.Second)
 
-	// returns (conn, nil) in most cases, even if conn is not valid
-	db, err := sql.Open("postgres", connString)
+	query := url.Values{}
+	query.Set("sslmode", sslMode)
+	query.Set("connect_timeout", strconv.FormatInt(timeoutSec, 10))
+	if cfg.SSLRootCert != "" {
+		query.Set("sslrootcert", cfg.SSLRootCert)
+	}
+	if cfg.SSLCert != "" {
+		query.Set("sslcert", cfg.SSLCert)
+	}
+	if cfg.SSLKey != "" {
+		query.Set("sslkey", cfg.SSLKey)
+	}
+
+	u := url.URL{
+		Scheme: "postgres",
+		User: url.UserPassword(cfg.User, cfg.Password),
+		Host: cfg.Host + ":" + cfg.Port,
+		Path: "/" + cfg.DBName,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// builds DSN on every new connection, see PostgreSQLConfig.PasswordFunc
+type connector struct {
+	cfg		PostgreSQLConfig
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	cfg := c.cfg
+	if cfg.PasswordFunc != nil {
+		cfg.Password = cfg.PasswordFunc()
+	}
+
+	pqConnector, err := pq.NewConnector(connString(&cfg))
 	if err != nil {
 		return nil, err
 	}
+	return pqConnector.Connect(ctx)
+}
+
+func (c *connector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// connect to Postgres with creds, TLS and pool settings from config
+func NewPostgresDB(cfg *PostgreSQLConfig) (*sql.DB, error) {
+	// never fails, DSN is checked by the first connection
+	db := sql.OpenDB(&connector{ cfg: *cfg })
+
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
 
-	// timeout connection after 2 sec
-	if err = db.Ping(); err != nil {
+	// every attempt times out after ConnectTimeout
+	if err := retry(cfg.Retry, db.Ping); err != nil {
+		// `_ =` to silence lint, pool is useless anyway
+		_ = db.Close()
 		return nil, err
 	}
 	return db, nil
@@ -0,0 +1,267 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+// arbitrary, but the same for every replica of the service,
+// so only one of them migrates at a time
+const migrationLockKey = 6_510_931_775_423
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version bigint PRIMARY KEY,
+	name text NOT NULL,
+	applied_at timestamptz NOT NULL DEFAULT now()
+);`
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version		uint64
+	Name		string
+	Up			string
+	Down		string
+}
+
+// reads `<version>_<name>.up.sql` and `<version>_<name>.down.sql` files of dir,
+// returns migrations sorted by version
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint64]*Migration, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+
+		fileName := e.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s: expected .up.sql or .down.sql suffix", fileName)
+		}
+
+		versionString, name, found := strings.Cut(strings.TrimSuffix(fileName, "." + direction + ".sql"), "_")
+		if !found {
+			return nil, fmt.Errorf("migration %s: expected <version>_<name> file name", fileName)
+		}
+
+		version, err := strconv.ParseUint(versionString, 10, 63)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", fileName, versionString)
+		}
+
+		body, err := fs.ReadFile(fsys, path.Join(dir, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{ Version: version, Name: name }
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %s: version %d is already used by %s", fileName, version, m.Name)
+		}
+
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s: missing .up.sql", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrator applies and reverts migrations, bookkeeping is done in `schema_migrations`
+type Migrator struct {
+	DB			*sql.DB
+	Migrations	[]Migration
+}
+
+// migrator with migrations embedded into the binary
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := LoadMigrations(embeddedMigrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		DB: db,
+		Migrations: migrations,
+	}, nil
+}
+
+// runs fn on a single connection holding the migration lock
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// session level lock, has to be taken and released on the same connection
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return err
+	}
+	defer func() {
+		// `_, _ =` to silence lint, lock is released with the connection anyway
+		_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+	}()
+
+	if _, err := conn.ExecContext(ctx, createMigrationsTable); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// versions present in `schema_migrations`, newest first
+func appliedVersions(ctx context.Context, conn *sql.Conn) ([]uint64, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM schema_migrations ORDER BY version DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make([]uint64, 0, 16)
+	for rows.Next() {
+		var v uint64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// runs migration statement and its bookkeeping in one transaction
+func migrateStep(ctx context.Context, conn *sql.Conn, statement string, bookkeeping string, params ...interface{}) error {
+	return inTransaction(ctx, conn, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, bookkeeping, params...)
+		return err
+	})
+}
+
+// Up applies every pending migration in version order, returns applied versions
+func (m *Migrator) Up(ctx context.Context) ([]uint64, error) {
+	done := make([]uint64, 0, len(m.Migrations))
+
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		applied := make(map[uint64]bool, len(versions))
+		for _, v := range versions {
+			applied[v] = true
+		}
+
+		for _, mig := range m.Migrations {
+			if applied[mig.Version] {
+				continue
+			}
+
+			err := migrateStep(ctx, conn, mig.Up,
+				"INSERT INTO schema_migrations(version, name) VALUES ($1, $2)", mig.Version, mig.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig.Version)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts `steps` latest applied migrations, returns reverted versions
+func (m *Migrator) Down(ctx context.Context, steps int) ([]uint64, error) {
+	done := make([]uint64, 0, steps)
+
+	known := make(map[uint64]Migration, len(m.Migrations))
+	for _, mig := range m.Migrations {
+		known[mig.Version] = mig
+	}
+
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if steps < len(versions) {
+			versions = versions[:steps]
+		}
+
+		for _, v := range versions {
+			mig, ok := known[v]
+			if !ok {
+				return fmt.Errorf("migration %d is applied, but unknown to this binary", v)
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %d_%s: missing .down.sql", mig.Version, mig.Name)
+			}
+
+			err := migrateStep(ctx, conn, mig.Down,
+				"DELETE FROM schema_migrations WHERE version = $1", mig.Version)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig.Version)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Version is the latest applied migration, 0 for a database without any
+func (m *Migrator) Version(ctx context.Context) (uint64, error) {
+	var version uint64
+
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if len(versions) > 0 {
+			version = versions[0]
+		}
+		return nil
+	})
+	return version, err
+}
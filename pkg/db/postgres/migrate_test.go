@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_second.up.sql":		{ Data: []byte("CREATE TABLE b ();") },
+		"m/0001_first.up.sql":		{ Data: []byte("CREATE TABLE a ();") },
+		"m/0001_first.down.sql":	{ Data: []byte("DROP TABLE a;") },
+	}
+
+	migrations, err := LoadMigrations(fsys, "m")
+	assert.NoError(t, err)
+	assert.Equal(t, []Migration{
+		{ Version: 1, Name: "first", Up: "CREATE TABLE a ();", Down: "DROP TABLE a;" },
+		{ Version: 2, Name: "second", Up: "CREATE TABLE b ();" },
+	}, migrations)
+}
+
+func TestLoadMigrationsInvalid(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"no direction":		{ "m/0001_first.sql": {} },
+		"no name":			{ "m/0001.up.sql": {} },
+		"bad version":		{ "m/first_a.up.sql": {} },
+		"zero version":		{ "m/0000_first.up.sql": {} },
+		"no up":			{ "m/0001_first.down.sql": {} },
+		"version reused":	{ "m/0001_first.up.sql": {}, "m/0001_second.up.sql": {} },
+	}
+
+	for name, fsys := range cases {
+		fsys := fsys
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := LoadMigrations(fsys, "m")
+			assert.Error(t, err)
+		})
+	}
+}
+
+// migrations shipped with the binary must be loadable and reversible
+func TestEmbeddedMigrations(t *testing.T) {
+	m, err := NewMigrator(nil)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, m.Migrations)
+
+	for i, mig := range m.Migrations {
+		assert.Equal(t, uint64(i + 1), mig.Version, "versions are expected to have no gaps")
+		assert.NotEmpty(t, mig.Down, "migration %d_%s has no .down.sql", mig.Version, mig.Name)
+	}
+}
+
+func testMigrator(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return &Migrator{
+		DB: db,
+		Migrations: []Migration{
+			{ Version: 1, Name: "first", Up: "CREATE TABLE a ();", Down: "DROP TABLE a;" },
+			{ Version: 2, Name: "second", Up: "CREATE TABLE b ();", Down: "DROP TABLE b;" },
+		},
+	}, mock
+}
+
+func expectLock(mock sqlmock.Sqlmock) {
+	mock.
+		ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock($1)")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.
+		ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.
+		ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestMigratorUp(t *testing.T) {
+	m, mock := testMigrator(t)
+
+	expectLock(mock)
+	mock.
+		ExpectQuery("SELECT version FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.
+		ExpectExec(regexp.QuoteMeta("CREATE TABLE b ();")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.
+		ExpectExec(regexp.QuoteMeta("INSERT INTO schema_migrations(version, name) VALUES ($1, $2)")).
+		WithArgs(2, "second").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	applied, err := m.Up(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{2}, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorUpFail(t *testing.T) {
+	m, mock := testMigrator(t)
+
+	expectLock(mock)
+	mock.
+		ExpectQuery("SELECT version FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectBegin()
+	mock.
+		ExpectExec(regexp.QuoteMeta("CREATE TABLE a ();")).
+		WillReturnError(errors.New("syntax error"))
+	mock.ExpectRollback()
+	expectUnlock(mock)
+
+	applied, err := m.Up(context.Background())
+	assert.Error(t, err)
+	assert.Empty(t, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorDown(t *testing.T) {
+	m, mock := testMigrator(t)
+
+	expectLock(mock)
+	mock.
+		ExpectQuery("SELECT version FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2).AddRow(1))
+	mock.ExpectBegin()
+	mock.
+		ExpectExec(regexp.QuoteMeta("DROP TABLE b;")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.
+		ExpectExec(regexp.QuoteMeta("DELETE FROM schema_migrations WHERE version = $1")).
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	reverted, err := m.Down(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{2}, reverted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorVersion(t *testing.T) {
+	m, mock := testMigrator(t)
+
+	expectLock(mock)
+	mock.
+		ExpectQuery("SELECT version FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2).AddRow(1))
+	expectUnlock(mock)
+
+	version, err := m.Version(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
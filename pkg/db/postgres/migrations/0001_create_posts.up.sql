@@ -1,3 +1,6 @@
+-- uuid_generate_v4()
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
 CREATE TABLE IF NOT EXISTS posts (
 	uuid uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
 	content text,
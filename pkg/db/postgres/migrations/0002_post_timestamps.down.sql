@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS posts_created_at_uuid_idx;
+
+ALTER TABLE posts
+	DROP COLUMN IF EXISTS created_at,
+	DROP COLUMN IF EXISTS updated_at;
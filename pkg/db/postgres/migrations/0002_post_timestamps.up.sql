@@ -0,0 +1,6 @@
+ALTER TABLE posts
+	ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now(),
+	ADD COLUMN IF NOT EXISTS updated_at timestamptz NOT NULL DEFAULT now();
+
+-- feed is read in (created_at, uuid) order
+CREATE INDEX IF NOT EXISTS posts_created_at_uuid_idx ON posts (created_at, uuid);
@@ -0,0 +1,2 @@
+-- soft delete, set back to NULL to restore the post
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
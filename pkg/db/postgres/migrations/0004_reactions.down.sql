@@ -0,0 +1,3 @@
+-- counters in `posts` keep their values
+DROP TABLE IF EXISTS reactions;
+DROP FUNCTION IF EXISTS reactions_count();
@@ -0,0 +1,31 @@
+-- one reaction per user and post, counters in `posts` follow this table
+CREATE TABLE IF NOT EXISTS reactions (
+	post_uuid uuid NOT NULL REFERENCES posts (uuid),
+	user_id text NOT NULL,
+	kind text NOT NULL CHECK (kind IN ('like', 'dislike')),
+	created_at timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY (post_uuid, user_id)
+);
+
+-- applies every change of `reactions` to posts.likes and posts.dislikes
+CREATE OR REPLACE FUNCTION reactions_count() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP IN ('UPDATE', 'DELETE') THEN
+		UPDATE posts SET
+			likes = likes - (OLD.kind = 'like')::int,
+			dislikes = dislikes - (OLD.kind = 'dislike')::int
+		WHERE uuid = OLD.post_uuid;
+	END IF;
+	IF TG_OP IN ('INSERT', 'UPDATE') THEN
+		UPDATE posts SET
+			likes = likes + (NEW.kind = 'like')::int,
+			dislikes = dislikes + (NEW.kind = 'dislike')::int
+		WHERE uuid = NEW.post_uuid;
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS reactions_count ON reactions;
+CREATE TRIGGER reactions_count AFTER INSERT OR UPDATE OF kind OR DELETE ON reactions
+	FOR EACH ROW EXECUTE FUNCTION reactions_count();
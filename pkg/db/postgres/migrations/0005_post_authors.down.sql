@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS posts_author_created_at_uuid_idx;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS author_id;
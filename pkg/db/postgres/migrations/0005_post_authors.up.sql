@@ -0,0 +1,5 @@
+-- empty for posts published before authorship was tracked
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS author_id text NOT NULL DEFAULT '';
+
+-- per-author feeds
+CREATE INDEX IF NOT EXISTS posts_author_created_at_uuid_idx ON posts (author_id, created_at, uuid);
@@ -0,0 +1,5 @@
+-- full-text search over content. `russian` config stems Latin words as English too
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS search tsvector
+	GENERATED ALWAYS AS (to_tsvector('russian', content)) STORED;
+
+CREATE INDEX IF NOT EXISTS posts_search_idx ON posts USING GIN (search);
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS posts_hot_score_idx;
+DROP INDEX IF EXISTS posts_controversial_score_idx;
+DROP INDEX IF EXISTS posts_top_score_idx;
+
+ALTER TABLE posts
+	DROP COLUMN IF EXISTS hot_score,
+	DROP COLUMN IF EXISTS controversial_score,
+	DROP COLUMN IF EXISTS top_score;
+
+DROP FUNCTION IF EXISTS post_hot_score(int, int, timestamptz);
+DROP FUNCTION IF EXISTS post_controversial_score(int, int);
+DROP FUNCTION IF EXISTS post_top_score(int, int);
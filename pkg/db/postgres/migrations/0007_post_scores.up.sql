@@ -0,0 +1,45 @@
+-- scores of `GET /posts?sort=`, mirrored by models.TopScore, ControversialScore and HotScore.
+-- None of them depends on the current time, so they are stored and indexed.
+-- Generated columns are recomputed whenever reactions_count() updates the counters
+
+-- lower bound of Wilson score interval for the share of likes, z = 1.96
+CREATE OR REPLACE FUNCTION post_top_score(likes int, dislikes int) RETURNS double precision
+	LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+	SELECT CASE WHEN n = 0 THEN 0
+		ELSE (p + 1.9208 / n - 1.96 * sqrt((p * (1 - p) + 0.9604 / n) / n)) / (1 + 3.8416 / n)
+	END
+	FROM (SELECT
+		coalesce(likes, 0)::float8 / nullif(coalesce(likes, 0) + coalesce(dislikes, 0), 0) AS p,
+		(coalesce(likes, 0) + coalesce(dislikes, 0))::float8 AS n
+	) AS v
+$$;
+
+-- number of votes raised to the power of their balance, zero if nobody disagrees
+CREATE OR REPLACE FUNCTION post_controversial_score(likes int, dislikes int) RETURNS double precision
+	LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+	SELECT CASE WHEN coalesce(likes, 0) = 0 OR coalesce(dislikes, 0) = 0 THEN 0
+		ELSE power((likes + dislikes)::float8, least(likes, dislikes)::float8 / greatest(likes, dislikes))
+	END
+$$;
+
+-- log10 of vote difference plus age in 45000s units since 2022-07-01.
+-- extract() on timestamptz is only STABLE, epoch does not depend on time zone though
+CREATE OR REPLACE FUNCTION post_hot_score(likes int, dislikes int, created_at timestamptz) RETURNS double precision
+	LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+	SELECT sign(s) * log(greatest(abs(s), 1)) + (extract(epoch FROM created_at)::float8 - 1656633600) / 45000
+	FROM (SELECT (coalesce(likes, 0) - coalesce(dislikes, 0))::float8 AS s) AS v
+$$;
+
+-- rewrites the table, run on a quiet hour for large ones
+ALTER TABLE posts
+	ADD COLUMN IF NOT EXISTS top_score double precision
+		GENERATED ALWAYS AS (post_top_score(likes, dislikes)) STORED,
+	ADD COLUMN IF NOT EXISTS controversial_score double precision
+		GENERATED ALWAYS AS (post_controversial_score(likes, dislikes)) STORED,
+	ADD COLUMN IF NOT EXISTS hot_score double precision
+		GENERATED ALWAYS AS (post_hot_score(likes, dislikes, created_at)) STORED;
+
+-- feed is read in (score, created_at, uuid) order, deleted posts are never listed
+CREATE INDEX IF NOT EXISTS posts_top_score_idx ON posts (top_score, created_at, uuid) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS posts_controversial_score_idx ON posts (controversial_score, created_at, uuid) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS posts_hot_score_idx ON posts (hot_score, created_at, uuid) WHERE deleted_at IS NULL;
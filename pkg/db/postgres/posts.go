@@ -0,0 +1,236 @@
+package postgres
+
+import (
+	"fmt"
+	"errors"
+	"context"
+	"strconv"
+	"strings"
+	"database/sql"
+
+	"feed-service/internal/models"
+)
+
+// columns of `posts` in the order of postFields
+const postColumns = "uuid, author_id, content, likes, dislikes, created_at, updated_at"
+
+// scan destination for a row selected with postColumns
+func postFields(p *models.Post) []interface{} {
+	return []interface{}{&p.UUID, &p.AuthorID, &p.Content, &p.Likes, &p.Dislikes, &p.CreatedAt, &p.UpdatedAt}
+}
+
+const (
+	createPostQuery = "INSERT INTO posts(author_id, content) VALUES ($1, $2) RETURNING " + postColumns + ";"
+	getPostQuery = "SELECT " + postColumns + " FROM posts WHERE uuid = $1 AND deleted_at IS NULL;"
+	// changed by the author only, see notChanged
+	updatePostQuery = "UPDATE posts SET content = $1, updated_at = now() WHERE uuid = $2 AND author_id = $3 AND deleted_at IS NULL RETURNING " + postColumns + ";"
+	deletePostQuery = "UPDATE posts SET deleted_at = now() WHERE uuid = $1 AND author_id = $2 AND deleted_at IS NULL;"
+	postExistsQuery = "SELECT EXISTS (SELECT 1 FROM posts WHERE uuid = $1 AND deleted_at IS NULL);"
+
+	// no-op when the user already has the same reaction, so trigger does not count it twice
+	setReactionQuery = "INSERT INTO reactions(post_uuid, user_id, kind) SELECT uuid, $2, $3 FROM posts WHERE uuid = $1 AND deleted_at IS NULL " +
+		"ON CONFLICT (post_uuid, user_id) DO UPDATE SET kind = EXCLUDED.kind WHERE reactions.kind <> EXCLUDED.kind;"
+	clearReactionQuery = "DELETE FROM reactions WHERE post_uuid = $1 AND user_id = $2;"
+)
+
+// PostStore is models.PostStore on top of `posts` and `reactions` tables
+type PostStore struct {
+	DB		*sql.DB
+}
+
+func NewPostStore(db *sql.DB) *PostStore {
+	return &PostStore{
+		DB: db,
+	}
+}
+
+// sql.ErrNoRows means the post is missing or deleted
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ErrPostNotFound
+	}
+	return err
+}
+
+// tells why a change made by the author only touched no rows:
+// ErrNotAuthor if the post is there, ErrPostNotFound otherwise
+func (s *PostStore) notChanged(ctx context.Context, uuid string) error {
+	var exists bool
+	if err := s.DB.QueryRowContext(ctx, postExistsQuery, uuid).Scan(&exists); err != nil {
+		return contextError(ctx, err)
+	}
+	if exists {
+		return models.ErrNotAuthor
+	}
+	return models.ErrPostNotFound
+}
+
+// driver reports a query canceled by ctx as its own error (`pq: canceling statement due to user request`),
+// ctx.Err() is added so callers can tell timeout from cancellation
+func contextError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, ctx.Err()) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ctx.Err(), err)
+}
+
+func (s *PostStore) Create(ctx context.Context, authorID string, content string) (models.Post, error) {
+	var post models.Post
+	err := s.DB.QueryRowContext(ctx, createPostQuery, authorID, content).Scan(postFields(&post)...)
+	return post, contextError(ctx, err)
+}
+
+func (s *PostStore) Get(ctx context.Context, uuid string) (models.Post, error) {
+	var post models.Post
+	err := s.DB.QueryRowContext(ctx, getPostQuery, uuid).Scan(postFields(&post)...)
+	return post, notFound(contextError(ctx, err))
+}
+
+// stored score columns by sort, see migration 0007_post_scores
+var scoreColumns = map[models.PostSort]string{
+	models.SortTop: "top_score",
+	models.SortControversial: "controversial_score",
+	models.SortHot: "hot_score",
+}
+
+// parameters of a query being built, placeholders are numbered in the order of registration
+type queryParams []interface{}
+
+// registers query parameter, returns its placeholder
+func (p *queryParams) arg(v interface{}) string {
+	*p = append(*p, v)
+	return "$" + strconv.Itoa(len(*p))
+}
+
+// builds SELECT of a feed page, conditions refer to params by their placeholders.
+// Sorts other than SortNew select the score as the last column
+func listQuery(opts models.ListOptions) (string, []interface{}) {
+	var params queryParams
+	arg := params.arg
+
+	// soft deleted posts are never shown
+	conds := []string{"deleted_at IS NULL"}
+	if opts.AuthorID != "" {
+		conds = append(conds, "author_id = " + arg(opts.AuthorID))
+	}
+	if !opts.Since.IsZero() {
+		conds = append(conds, "created_at >= " + arg(opts.Since))
+	}
+	if !opts.Until.IsZero() {
+		conds = append(conds, "created_at < " + arg(opts.Until))
+	}
+
+	cmp, direction := "<", "DESC"
+	if opts.Order == models.OrderAsc {
+		cmp, direction = ">", "ASC"
+	}
+
+	columns := postColumns
+	// uuid breaks ties between equal timestamps, so the order is stable between pages
+	key := []string{"created_at", "uuid"}
+	score, scored := scoreColumns[opts.Sort]
+	if scored {
+		columns += ", " + score
+		key = append([]string{score}, key...)
+	}
+
+	if opts.After != nil {
+		var values []string
+		if scored {
+			values = append(values, arg(opts.After.Score))
+		}
+		values = append(values, arg(opts.After.CreatedAt), arg(opts.After.UUID))
+		conds = append(conds, "(" + strings.Join(key, ", ") + ") " + cmp + " (" + strings.Join(values, ", ") + ")")
+	}
+
+	queryString := "SELECT " + columns + " FROM posts WHERE " + strings.Join(conds, " AND ")
+	queryString += " ORDER BY " + strings.Join(key, " " + direction + ", ") + " " + direction
+	if opts.Limit > 0 {
+		queryString += " LIMIT " + arg(opts.Limit)
+	}
+	return queryString, params
+}
+
+func (s *PostStore) List(ctx context.Context, opts models.ListOptions) ([]models.Post, error) {
+	posts, err := s.list(ctx, opts)
+	return posts, contextError(ctx, err)
+}
+
+func (s *PostStore) list(ctx context.Context, opts models.ListOptions) ([]models.Post, error) {
+	queryString, params := listQuery(opts)
+
+	rows, err := s.DB.QueryContext(ctx, queryString, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	_, scored := scoreColumns[opts.Sort]
+	posts := make([]models.Post, 0, 32)
+	for rows.Next() {
+		post := models.Post{}
+		fields := postFields(&post)
+		if scored {
+			fields = append(fields, &post.Score)
+		}
+		if err := rows.Scan(fields...); err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+func (s *PostStore) Update(ctx context.Context, uuid string, authorID string, content string) (models.Post, error) {
+	var post models.Post
+	err := s.DB.QueryRowContext(ctx, updatePostQuery, content, uuid, authorID).Scan(postFields(&post)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return post, s.notChanged(ctx, uuid)
+	}
+	return post, contextError(ctx, err)
+}
+
+func (s *PostStore) React(ctx context.Context, uuid string, userID string, reaction models.Reaction) (models.Post, bool, error) {
+	var post models.Post
+	var changed bool
+
+	// counters are updated by trigger, read them in the same transaction
+	err := inTransaction(ctx, s.DB, func(tx *sql.Tx) error {
+		var res sql.Result
+		var err error
+		if reaction == models.ReactionNone {
+			res, err = tx.ExecContext(ctx, clearReactionQuery, uuid, userID)
+		} else {
+			res, err = tx.ExecContext(ctx, setReactionQuery, uuid, userID, reaction)
+		}
+		if err != nil {
+			return err
+		}
+
+		// same reaction again is skipped by `ON CONFLICT ... WHERE`, absent one is not deleted
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		changed = affected > 0
+
+		return tx.QueryRowContext(ctx, getPostQuery, uuid).Scan(postFields(&post)...)
+	})
+	return post, changed, notFound(contextError(ctx, err))
+}
+
+func (s *PostStore) Delete(ctx context.Context, uuid string, authorID string) error {
+	res, err := s.DB.ExecContext(ctx, deletePostQuery, uuid, authorID)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return s.notChanged(ctx, uuid)
+	}
+	return nil
+}
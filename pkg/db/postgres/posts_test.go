@@ -0,0 +1,470 @@
+package postgres
+
+import (
+	"time"
+	"errors"
+	"context"
+	"regexp"
+	"testing"
+	"database/sql/driver"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"feed-service/internal/models"
+)
+
+var postColumnNames = []string{"uuid", "author_id", "content", "likes", "dislikes", "created_at", "updated_at"}
+
+var mockPost = models.Post {
+	UUID: "78204138-90c6-49f7-90d9-1461d5d640f8",
+	AuthorID: "penny",
+	Content: "simple text",
+	Likes: 123,
+	Dislikes: 321,
+	CreatedAt: time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC),
+	UpdatedAt: time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC),
+}
+
+func postRows(posts ...models.Post) *sqlmock.Rows {
+	rows := sqlmock.NewRows(postColumnNames)
+	for _, p := range posts {
+		rows.AddRow(p.UUID, p.AuthorID, p.Content, p.Likes, p.Dislikes, p.CreatedAt, p.UpdatedAt)
+	}
+	return rows
+}
+
+func testStore(t *testing.T) (*PostStore, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return NewPostStore(db), mock
+}
+
+var errConn = errors.New("connection refused")
+
+func TestPostStoreCreate(t *testing.T) {
+	s, mock := testStore(t)
+
+	mock.
+		ExpectQuery(regexp.QuoteMeta("INSERT INTO posts(author_id, content) VALUES ($1, $2) RETURNING uuid, author_id, content, likes, dislikes, created_at, updated_at;")).
+		WithArgs("penny", "simple text").
+		WillReturnRows(postRows(mockPost))
+
+	post, err := s.Create(context.Background(), "penny", "simple text")
+	assert.NoError(t, err)
+	assert.Equal(t, mockPost, post)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostStoreGet(t *testing.T) {
+	s, mock := testStore(t)
+
+	mock.
+		ExpectQuery(regexp.QuoteMeta("SELECT uuid, author_id, content, likes, dislikes, created_at, updated_at FROM posts WHERE uuid = $1 AND deleted_at IS NULL;")).
+		WithArgs(mockPost.UUID).
+		WillReturnRows(postRows(mockPost))
+
+	post, err := s.Get(context.Background(), mockPost.UUID)
+	assert.NoError(t, err)
+	assert.Equal(t, mockPost, post)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostStoreGetNotFound(t *testing.T) {
+	s, mock := testStore(t)
+
+	mock.
+		ExpectQuery("SELECT (.+) FROM posts WHERE uuid = ").
+		WillReturnRows(postRows())
+
+	_, err := s.Get(context.Background(), mockPost.UUID)
+	assert.ErrorIs(t, err, models.ErrPostNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// driver gives up on a canceled query with its own error, ctx.Err() has to be reported along
+func TestPostStoreGetTimeout(t *testing.T) {
+	s, mock := testStore(t)
+
+	mock.
+		ExpectQuery("SELECT (.+) FROM posts WHERE uuid = ").
+		WillDelayFor(time.Second).
+		WillReturnRows(postRows(mockPost))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10 * time.Millisecond)
+	defer cancel()
+
+	_, err := s.Get(ctx, mockPost.UUID)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, models.ErrPostNotFound)
+}
+
+func TestContextError(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.NoError(t, contextError(canceled, nil))
+	assert.Equal(t, errConn, contextError(context.Background(), errConn))
+	assert.Equal(t, context.Canceled, contextError(canceled, context.Canceled))
+
+	err := contextError(canceled, errConn)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, err, errConn)
+}
+
+func TestListQuery(t *testing.T) {
+	since := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2022, 7, 2, 0, 0, 0, 0, time.UTC)
+	after := models.PostPosition{ CreatedAt: until, UUID: mockPost.UUID }
+	scored := models.PostPosition{ Score: 1.5, CreatedAt: until, UUID: mockPost.UUID }
+
+	cases := map[string]struct {
+		opts	models.ListOptions
+		query	string
+		params	[]interface{}
+	}{
+		"everything": {
+			opts: models.ListOptions{},
+			query: "SELECT uuid, author_id, content, likes, dislikes, created_at, updated_at FROM posts WHERE deleted_at IS NULL ORDER BY created_at DESC, uuid DESC",
+		},
+		"page": {
+			opts: models.ListOptions{ Order: models.OrderDesc, Limit: 2 },
+			query: "SELECT uuid, author_id, content, likes, dislikes, created_at, updated_at FROM posts WHERE deleted_at IS NULL ORDER BY created_at DESC, uuid DESC LIMIT $1",
+			params: []interface{}{2},
+		},
+		"after cursor": {
+			opts: models.ListOptions{ After: &after, Limit: 2 },
+			query: "SELECT uuid, author_id, content, likes, dislikes, created_at, updated_at FROM posts WHERE deleted_at IS NULL AND (created_at, uuid) < ($1, $2) ORDER BY created_at DESC, uuid DESC LIMIT $3",
+			params: []interface{}{after.CreatedAt, after.UUID, 2},
+		},
+		"oldest first in range": {
+			opts: models.ListOptions{ Order: models.OrderAsc, Since: since, Until: until, After: &after },
+			query: "SELECT uuid, author_id, content, likes, dislikes, created_at, updated_at FROM posts WHERE deleted_at IS NULL AND created_at >= $1 AND created_at < $2 AND (created_at, uuid) > ($3, $4) ORDER BY created_at ASC, uuid ASC",
+			params: []interface{}{since, until, after.CreatedAt, after.UUID},
+		},
+		"top": {
+			opts: models.ListOptions{ Sort: models.SortTop, Limit: 2 },
+			query: "SELECT uuid, author_id, content, likes, dislikes, created_at, updated_at, top_score FROM posts WHERE deleted_at IS NULL ORDER BY top_score DESC, created_at DESC, uuid DESC LIMIT $1",
+			params: []interface{}{2},
+		},
+		"hot after cursor": {
+			opts: models.ListOptions{ Sort: models.SortHot, After: &scored },
+			query: "SELECT uuid, author_id, content, likes, dislikes, created_at, updated_at, hot_score FROM posts WHERE deleted_at IS NULL AND (hot_score, created_at, uuid) < ($1, $2, $3) ORDER BY hot_score DESC, created_at DESC, uuid DESC",
+			params: []interface{}{scored.Score, scored.CreatedAt, scored.UUID},
+		},
+		"least controversial by author": {
+			opts: models.ListOptions{ AuthorID: "penny", Sort: models.SortControversial, Order: models.OrderAsc, After: &scored },
+			query: "SELECT uuid, author_id, content, likes, dislikes, created_at, updated_at, controversial_score FROM posts WHERE deleted_at IS NULL AND author_id = $1 AND (controversial_score, created_at, uuid) > ($2, $3, $4) ORDER BY controversial_score ASC, created_at ASC, uuid ASC",
+			params: []interface{}{"penny", scored.Score, scored.CreatedAt, scored.UUID},
+		},
+		"author": {
+			opts: models.ListOptions{ AuthorID: "penny", Limit: 11 },
+			query: "SELECT uuid, author_id, content, likes, dislikes, created_at, updated_at FROM posts WHERE deleted_at IS NULL AND author_id = $1 ORDER BY created_at DESC, uuid DESC LIMIT $2",
+			params: []interface{}{"penny", 11},
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			query, params := listQuery(tc.opts)
+			assert.Equal(t, tc.query, query)
+			assert.Equal(t, tc.params, params)
+		})
+	}
+}
+
+func TestPostStoreList(t *testing.T) {
+	s, mock := testStore(t)
+
+	mock.
+		ExpectQuery(regexp.QuoteMeta("SELECT uuid, author_id, content, likes, dislikes, created_at, updated_at FROM posts WHERE deleted_at IS NULL AND author_id = $1 ORDER BY created_at DESC, uuid DESC LIMIT $2")).
+		WithArgs("penny", 2).
+		WillReturnRows(postRows(mockPost, mockPost))
+
+	posts, err := s.List(context.Background(), models.ListOptions{ AuthorID: "penny", Limit: 2 })
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Post{mockPost, mockPost}, posts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// score is scanned into Post.Score for the cursor
+func TestPostStoreListTop(t *testing.T) {
+	s, mock := testStore(t)
+
+	mock.
+		ExpectQuery(regexp.QuoteMeta("SELECT uuid, author_id, content, likes, dislikes, created_at, updated_at, top_score FROM posts WHERE deleted_at IS NULL ORDER BY top_score DESC, created_at DESC, uuid DESC LIMIT $1")).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(append(postColumnNames, "top_score")).
+			AddRow(mockPost.UUID, mockPost.AuthorID, mockPost.Content, mockPost.Likes, mockPost.Dislikes, mockPost.CreatedAt, mockPost.UpdatedAt, 0.2))
+
+	posts, err := s.List(context.Background(), models.ListOptions{ Sort: models.SortTop, Limit: 2 })
+	assert.NoError(t, err)
+
+	want := mockPost
+	want.Score = 0.2
+	assert.Equal(t, []models.Post{want}, posts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostStoreListEmpty(t *testing.T) {
+	s, mock := testStore(t)
+
+	mock.
+		ExpectQuery("SELECT (.+) FROM posts").
+		WillReturnRows(postRows())
+
+	posts, err := s.List(context.Background(), models.ListOptions{})
+	assert.NoError(t, err)
+	// encoded as `[]`, not `null`
+	assert.NotNil(t, posts)
+	assert.Empty(t, posts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostStoreListErr(t *testing.T) {
+	t.Run("query", func(t *testing.T) {
+		s, mock := testStore(t)
+
+		mock.
+			ExpectQuery("SELECT (.+) FROM posts").
+			WillReturnError(errConn)
+
+		_, err := s.List(context.Background(), models.ListOptions{})
+		assert.ErrorIs(t, err, errConn)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("scan", func(t *testing.T) {
+		s, mock := testStore(t)
+
+		// wrong columns
+		mock.
+			ExpectQuery("SELECT (.+) FROM posts").
+			WillReturnRows(sqlmock.NewRows([]string{"uuid"}).AddRow(mockPost.UUID))
+
+		_, err := s.List(context.Background(), models.ListOptions{})
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostStoreUpdate(t *testing.T) {
+	s, mock := testStore(t)
+
+	mock.
+		ExpectQuery(regexp.QuoteMeta("UPDATE posts SET content = $1, updated_at = now() WHERE uuid = $2 AND author_id = $3 AND deleted_at IS NULL RETURNING uuid, author_id, content, likes, dislikes, created_at, updated_at;")).
+		WithArgs("simple text", mockPost.UUID, "penny").
+		WillReturnRows(postRows(mockPost))
+
+	post, err := s.Update(context.Background(), mockPost.UUID, "penny", "simple text")
+	assert.NoError(t, err)
+	assert.Equal(t, mockPost, post)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostStoreUpdateNotFound(t *testing.T) {
+	s, mock := testStore(t)
+
+	mock.
+		ExpectQuery("UPDATE posts SET content").
+		WillReturnRows(postRows())
+	mock.
+		ExpectQuery(regexp.QuoteMeta(postExistsQuery)).
+		WithArgs(mockPost.UUID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	_, err := s.Update(context.Background(), mockPost.UUID, "penny", "simple text")
+	assert.ErrorIs(t, err, models.ErrPostNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostStoreUpdateNotAuthor(t *testing.T) {
+	s, mock := testStore(t)
+
+	mock.
+		ExpectQuery("UPDATE posts SET content").
+		WithArgs("pwned", mockPost.UUID, "bob").
+		WillReturnRows(postRows())
+	mock.
+		ExpectQuery(regexp.QuoteMeta(postExistsQuery)).
+		WithArgs(mockPost.UUID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	_, err := s.Update(context.Background(), mockPost.UUID, "bob", "pwned")
+	assert.ErrorIs(t, err, models.ErrNotAuthor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostStoreReact(t *testing.T) {
+	cases := map[models.Reaction]struct {
+		query	string
+		args	[]driver.Value
+	}{
+		models.ReactionLike:	{ setReactionQuery, []driver.Value{mockPost.UUID, "penny", "like"} },
+		models.ReactionDislike:	{ setReactionQuery, []driver.Value{mockPost.UUID, "penny", "dislike"} },
+		models.ReactionNone:	{ clearReactionQuery, []driver.Value{mockPost.UUID, "penny"} },
+	}
+
+	for reaction, tc := range cases {
+		s, mock := testStore(t)
+
+		mock.ExpectBegin()
+		mock.
+			ExpectExec(regexp.QuoteMeta(tc.query)).
+			WithArgs(tc.args...).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.
+			ExpectQuery(regexp.QuoteMeta(getPostQuery)).
+			WithArgs(mockPost.UUID).
+			WillReturnRows(postRows(mockPost))
+		mock.ExpectCommit()
+
+		post, changed, err := s.React(context.Background(), mockPost.UUID, "penny", reaction)
+		assert.NoError(t, err, reaction)
+		assert.Equal(t, mockPost, post, reaction)
+		assert.True(t, changed, reaction)
+		assert.NoError(t, mock.ExpectationsWereMet(), reaction)
+	}
+}
+
+// repeated reaction affects no rows, post is still returned
+func TestPostStoreReactUnchanged(t *testing.T) {
+	s, mock := testStore(t)
+
+	mock.ExpectBegin()
+	mock.
+		ExpectExec(regexp.QuoteMeta(setReactionQuery)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.
+		ExpectQuery(regexp.QuoteMeta(getPostQuery)).
+		WillReturnRows(postRows(mockPost))
+	mock.ExpectCommit()
+
+	post, changed, err := s.React(context.Background(), mockPost.UUID, "penny", models.ReactionLike)
+	assert.NoError(t, err)
+	assert.Equal(t, mockPost, post)
+	assert.False(t, changed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostStoreReactNotFound(t *testing.T) {
+	s, mock := testStore(t)
+
+	mock.ExpectBegin()
+	// INSERT ... SELECT inserts nothing for missing post
+	mock.
+		ExpectExec(regexp.QuoteMeta(setReactionQuery)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.
+		ExpectQuery(regexp.QuoteMeta(getPostQuery)).
+		WillReturnRows(postRows())
+	mock.ExpectRollback()
+
+	_, _, err := s.React(context.Background(), mockPost.UUID, "penny", models.ReactionLike)
+	assert.ErrorIs(t, err, models.ErrPostNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostStoreReactErr(t *testing.T) {
+	t.Run("begin", func(t *testing.T) {
+		s, mock := testStore(t)
+
+		mock.ExpectBegin().WillReturnError(errConn)
+
+		_, _, err := s.React(context.Background(), mockPost.UUID, "penny", models.ReactionLike)
+		assert.ErrorIs(t, err, errConn)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("exec", func(t *testing.T) {
+		s, mock := testStore(t)
+
+		mock.ExpectBegin()
+		mock.
+			ExpectExec(regexp.QuoteMeta(setReactionQuery)).
+			WillReturnError(errConn)
+		mock.ExpectRollback()
+
+		_, _, err := s.React(context.Background(), mockPost.UUID, "penny", models.ReactionLike)
+		assert.ErrorIs(t, err, errConn)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("commit", func(t *testing.T) {
+		s, mock := testStore(t)
+
+		mock.ExpectBegin()
+		mock.
+			ExpectExec(regexp.QuoteMeta(setReactionQuery)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.
+			ExpectQuery(regexp.QuoteMeta(getPostQuery)).
+			WillReturnRows(postRows(mockPost))
+		mock.ExpectCommit().WillReturnError(errConn)
+
+		_, _, err := s.React(context.Background(), mockPost.UUID, "penny", models.ReactionLike)
+		assert.ErrorIs(t, err, errConn)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostStoreDelete(t *testing.T) {
+	s, mock := testStore(t)
+
+	mock.
+		ExpectExec(regexp.QuoteMeta("UPDATE posts SET deleted_at = now() WHERE uuid = $1 AND author_id = $2 AND deleted_at IS NULL;")).
+		WithArgs(mockPost.UUID, "penny").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, s.Delete(context.Background(), mockPost.UUID, "penny"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostStoreDeleteNotFound(t *testing.T) {
+	s, mock := testStore(t)
+
+	// missing and already deleted posts alike
+	mock.
+		ExpectExec("UPDATE posts SET deleted_at").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.
+		ExpectQuery(regexp.QuoteMeta(postExistsQuery)).
+		WithArgs(mockPost.UUID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	assert.ErrorIs(t, s.Delete(context.Background(), mockPost.UUID, "penny"), models.ErrPostNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostStoreDeleteNotAuthor(t *testing.T) {
+	s, mock := testStore(t)
+
+	mock.
+		ExpectExec("UPDATE posts SET deleted_at").
+		WithArgs(mockPost.UUID, "bob").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.
+		ExpectQuery(regexp.QuoteMeta(postExistsQuery)).
+		WithArgs(mockPost.UUID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	assert.ErrorIs(t, s.Delete(context.Background(), mockPost.UUID, "bob"), models.ErrNotAuthor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostStoreDeleteErr(t *testing.T) {
+	s, mock := testStore(t)
+
+	mock.
+		ExpectExec("UPDATE posts SET deleted_at").
+		WillReturnError(errConn)
+
+	err := s.Delete(context.Background(), mockPost.UUID, "penny")
+	assert.ErrorIs(t, err, errConn)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
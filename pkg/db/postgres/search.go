@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"html"
+	"context"
+	"strings"
+
+	"feed-service/internal/models"
+)
+
+// ts_headline marks matches with these instead of HTML tags, so content can be escaped
+// around them. A post containing them gets a stray tag at worst, the rest is escaped anyway
+const (
+	startSel = "\x02"
+	stopSel = "\x03"
+)
+
+const headlineOptions = "StartSel=" + startSel + ", StopSel=" + stopSel + ", MaxWords=30, MinWords=10, MaxFragments=2, FragmentDelimiter=\" … \""
+
+// builds SELECT of a search page, conditions refer to params by their placeholders.
+// Matching rows are ranked and limited first, snippets are built for the page only
+func searchQuery(opts models.SearchOptions) (string, []interface{}) {
+	var params queryParams
+	arg := params.arg
+
+	// same config as `posts.search` column, otherwise the index is not used
+	query := "websearch_to_tsquery('russian', " + arg(opts.Query) + ")"
+
+	conds := []string{"search @@ q", "deleted_at IS NULL"}
+	if !opts.Since.IsZero() {
+		conds = append(conds, "created_at >= " + arg(opts.Since))
+	}
+	if !opts.Until.IsZero() {
+		conds = append(conds, "created_at < " + arg(opts.Until))
+	}
+	if opts.After != nil {
+		conds = append(conds, "(ts_rank(search, q), created_at, uuid) < (" +
+			arg(opts.After.Rank) + "::real, " + arg(opts.After.CreatedAt) + ", " + arg(opts.After.UUID) + ")")
+	}
+
+	page := "SELECT " + postColumns + ", ts_rank(search, q) AS rank, q FROM posts, " + query + " AS q" +
+		" WHERE " + strings.Join(conds, " AND ") +
+		" ORDER BY rank DESC, created_at DESC, uuid DESC"
+	if opts.Limit > 0 {
+		page += " LIMIT " + arg(opts.Limit)
+	}
+
+	queryString := "SELECT " + postColumns + ", rank, ts_headline('russian', content, q, " + arg(headlineOptions) + ") FROM (" + page + ") AS page" +
+		" ORDER BY rank DESC, created_at DESC, uuid DESC;"
+	return queryString, params
+}
+
+// escapes snippet made by ts_headline, turns match markers into <b></b>
+func highlight(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, startSel, "<b>")
+	return strings.ReplaceAll(snippet, stopSel, "</b>")
+}
+
+func (s *PostStore) Search(ctx context.Context, opts models.SearchOptions) ([]models.SearchResult, error) {
+	results, err := s.search(ctx, opts)
+	return results, contextError(ctx, err)
+}
+
+func (s *PostStore) search(ctx context.Context, opts models.SearchOptions) ([]models.SearchResult, error) {
+	queryString, params := searchQuery(opts)
+
+	rows, err := s.DB.QueryContext(ctx, queryString, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]models.SearchResult, 0, 32)
+	for rows.Next() {
+		res := models.SearchResult{}
+		if err := rows.Scan(append(postFields(&res.Post), &res.Rank, &res.Snippet)...); err != nil {
+			return nil, err
+		}
+		res.Snippet = highlight(res.Snippet)
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
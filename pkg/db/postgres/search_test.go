@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"time"
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"feed-service/internal/models"
+)
+
+const searchColumns = "SELECT uuid, author_id, content, likes, dislikes, created_at, updated_at"
+
+func TestSearchQuery(t *testing.T) {
+	since := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2022, 7, 2, 0, 0, 0, 0, time.UTC)
+	after := models.SearchPosition{ Rank: 0.5, CreatedAt: until, UUID: mockPost.UUID }
+
+	cases := map[string]struct {
+		opts	models.SearchOptions
+		query	string
+		params	[]interface{}
+	}{
+		"everything": {
+			opts: models.SearchOptions{ Query: "text" },
+			query: searchColumns + ", rank, ts_headline('russian', content, q, $2) FROM (" +
+				searchColumns + ", ts_rank(search, q) AS rank, q FROM posts, websearch_to_tsquery('russian', $1) AS q" +
+				" WHERE search @@ q AND deleted_at IS NULL ORDER BY rank DESC, created_at DESC, uuid DESC" +
+				") AS page ORDER BY rank DESC, created_at DESC, uuid DESC;",
+			params: []interface{}{"text", headlineOptions},
+		},
+		"page after cursor in range": {
+			opts: models.SearchOptions{ Query: "text", Since: since, Until: until, After: &after, Limit: 11 },
+			query: searchColumns + ", rank, ts_headline('russian', content, q, $8) FROM (" +
+				searchColumns + ", ts_rank(search, q) AS rank, q FROM posts, websearch_to_tsquery('russian', $1) AS q" +
+				" WHERE search @@ q AND deleted_at IS NULL AND created_at >= $2 AND created_at < $3" +
+				" AND (ts_rank(search, q), created_at, uuid) < ($4::real, $5, $6)" +
+				" ORDER BY rank DESC, created_at DESC, uuid DESC LIMIT $7" +
+				") AS page ORDER BY rank DESC, created_at DESC, uuid DESC;",
+			params: []interface{}{"text", since, until, after.Rank, after.CreatedAt, after.UUID, 11, headlineOptions},
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			query, params := searchQuery(tc.opts)
+			assert.Equal(t, tc.query, query)
+			assert.Equal(t, tc.params, params)
+		})
+	}
+}
+
+// content is escaped, only markers of ts_headline become tags
+func TestHighlight(t *testing.T) {
+	assert.Equal(t,
+		"&lt;script&gt; <b>simple</b> &amp; <b>text</b>",
+		highlight("<script> " + startSel + "simple" + stopSel + " & " + startSel + "text" + stopSel))
+}
+
+func TestPostStoreSearch(t *testing.T) {
+	s, mock := testStore(t)
+
+	mock.
+		ExpectQuery(regexp.QuoteMeta("FROM posts, websearch_to_tsquery('russian', $1) AS q")).
+		WithArgs("simple", 2, headlineOptions).
+		WillReturnRows(sqlmock.NewRows(append(postColumnNames, "rank", "ts_headline")).
+			AddRow(mockPost.UUID, mockPost.AuthorID, mockPost.Content, mockPost.Likes, mockPost.Dislikes, mockPost.CreatedAt, mockPost.UpdatedAt,
+				float32(0.0607927), startSel + "simple" + stopSel + " text"))
+
+	results, err := s.Search(context.Background(), models.SearchOptions{ Query: "simple", Limit: 2 })
+	assert.NoError(t, err)
+	assert.Equal(t, []models.SearchResult{{
+		Post: mockPost,
+		Rank: 0.0607927,
+		Snippet: "<b>simple</b> text",
+	}}, results)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostStoreSearchErr(t *testing.T) {
+	s, mock := testStore(t)
+
+	mock.
+		ExpectQuery("FROM posts, websearch_to_tsquery").
+		WillReturnError(errConn)
+
+	_, err := s.Search(context.Background(), models.SearchOptions{ Query: "simple" })
+	assert.ErrorIs(t, err, errConn)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,26 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+)
+
+// *sql.DB or *sql.Conn, the latter when a transaction has to run on a connection holding a session lock
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// runs fn in a transaction, commits it if fn succeeds and rolls back otherwise
+func inTransaction(ctx context.Context, db txBeginner, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		// `_ =` to silence lint, no way to react to this
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"database/sql"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestInTransaction(t *testing.T) {
+	t.Run("commit", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE posts").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err = inTransaction(context.Background(), db, func(tx *sql.Tx) error {
+			_, err := tx.Exec("UPDATE posts SET likes = 0")
+			return err
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		err = inTransaction(context.Background(), db, func(tx *sql.Tx) error {
+			return errConn
+		})
+		assert.ErrorIs(t, err, errConn)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	// migrations run on the connection holding the advisory lock
+	t.Run("conn", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		conn, err := db.Conn(context.Background())
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+
+		assert.NoError(t, inTransaction(context.Background(), conn, func(tx *sql.Tx) error { return nil }))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}